@@ -0,0 +1,104 @@
+package main
+
+import (
+	"image"
+	"image/color"
+
+	"wombatlord/photerm/photerm_src"
+	"wombatlord/photerm/src/dither"
+)
+
+// BrailleBase is the blank braille pattern, every other pattern is an offset from it.
+const BrailleBase = 0x2800
+
+// brailleDots maps the position of a pixel inside the 2x4 cell to its bit in the pattern.
+// Dots 1-6 run down the two columns, dots 7 and 8 were bolted onto the bottom later.
+var brailleDots = [4][2]rune{
+	{0x01, 0x08},
+	{0x02, 0x10},
+	{0x04, 0x20},
+	{0x40, 0x80},
+}
+
+// NoThreshold leaves the dots to be lit around the middle of the brightness range
+const NoThreshold = -1
+
+// MakeDotPicker returns whether the pixel at x, y of the region r gets a dot. The brightness of
+// the region is dithered with method down to lit or unlit, unless there's a threshold, then it's
+// a simple comparison. On a light background the dots draw the dark pixels instead.
+func MakeDotPicker(img image.Image, r photerm.Region, method dither.Method, threshold int) func(x, y int) bool {
+	cut := 127.5
+	if threshold != NoThreshold {
+		cut, method = float64(threshold), dither.None{}
+	}
+
+	plane := dither.NewPlane(r.Right-r.Left, r.Btm-r.Top, 1)
+	for y := 0; y < plane.H; y++ {
+		for x := 0; x < plane.W; x++ {
+			rgb := color.RGBAModel.Convert(img.At(r.Left+x, r.Top+y)).(color.RGBA)
+			if skipped(rgb) {
+				// left dark, so there's no error to pass on
+				continue
+			}
+			c := brightnessAt(img, r.Left+x, r.Top+y, rgb)
+			if Args.Theme == photerm.LightTheme {
+				c = 255 - c
+			}
+			plane.At(x, y)[0] = float64(c)
+		}
+	}
+
+	method.Dither(plane, 255, func(px []float64) {
+		if px[0] > cut {
+			px[0] = 255
+		} else {
+			px[0] = 0
+		}
+	})
+
+	return func(x, y int) bool {
+		return plane.At(x-r.Left, y-r.Top)[0] != 0
+	}
+}
+
+// RenderBraille returns the cells of a single frame, packing each 2x4 block of pixels
+// into a braille pattern. The cell is inked with the average colour of the lit dots.
+func RenderBraille(img image.Image, _ CharPalette, r photerm.Region) (grid Grid) {
+	grid = make(Grid, 0, (r.Btm-r.Top+3)/4)
+	dotAt := MakeDotPicker(img, r, dither.Methods[Args.Dither], Args.Threshold)
+
+	for y := r.Top; y < r.Btm; y += 4 {
+		row := make([]Cell, 0, (r.Right-r.Left+1)/2)
+		for x := r.Left; x < r.Right; x += 2 {
+			pattern := rune(BrailleBase)
+			var red, green, blue, lit, shown int
+
+			// visit each dot in the cell, clipping the block at the edges of the region
+			for dy := 0; dy < 4 && y+dy < r.Btm; dy++ {
+				for dx := 0; dx < 2 && x+dx < r.Right; dx++ {
+					rgb := color.RGBAModel.Convert(img.At(x+dx, y+dy)).(color.RGBA)
+					if skipped(rgb) {
+						continue
+					}
+					shown++
+					if !dotAt(x+dx, y+dy) {
+						continue
+					}
+					pattern |= brailleDots[dy][dx]
+					red, green, blue = red+int(rgb.R), green+int(rgb.G), blue+int(rgb.B)
+					lit++
+				}
+			}
+
+			// an empty cell has nothing to colour, and a transparent one isn't drawn at all
+			cell := Cell{Glyph: pattern, Transparent: shown == 0}
+			if lit != 0 {
+				cell.FG = shade(color.RGBA{R: uint8(red / lit), G: uint8(green / lit), B: uint8(blue / lit)})
+				cell.HasFG = true
+			}
+			row = append(row, cell)
+		}
+		grid = append(grid, row)
+	}
+	return grid
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"wombatlord/photerm/photerm_src"
+)
+
+// grey returns an opaque grey of brightness v
+func grey(v uint8) color.RGBA {
+	return color.RGBA{R: v, G: v, B: v, A: 255}
+}
+
+func TestBrailleDotOrder(t *testing.T) {
+	defer func(args photerm.Cli) { Args = args }(Args)
+	Args.Colours, Args.Dither, Args.Theme, Args.Threshold = photerm.TrueColour, "none", photerm.DarkTheme, NoThreshold
+
+	cases := []struct {
+		x, y  int
+		glyph rune
+	}{
+		// dots 1 to 3 down the left column, 4 to 6 down the right, then 7 and 8 along the bottom
+		{0, 0, '⠁'},
+		{0, 1, '⠂'},
+		{0, 2, '⠄'},
+		{1, 0, '⠈'},
+		{1, 1, '⠐'},
+		{1, 2, '⠠'},
+		{0, 3, '⡀'},
+		{1, 3, '⢀'},
+	}
+	for _, c := range cases {
+		img := image.NewRGBA(image.Rect(0, 0, 2, 4))
+		for i := 3; i < len(img.Pix); i += 4 {
+			img.Pix[i] = 255
+		}
+		img.SetRGBA(c.x, c.y, grey(255))
+		want := screenCell{c.glyph, "38;2;255;255;255", ""}
+		if got := replay(t, RenderBraille(img, CharPalette{}, photerm.Region{Right: 2, Btm: 4})).shows(0, 0); got != want {
+			t.Errorf("%d, %d: got %c %U, want %c %U", c.x, c.y, got.glyph, got.glyph, c.glyph, c.glyph)
+		}
+	}
+}
+
+func TestBrailleThreshold(t *testing.T) {
+	defer func(args photerm.Cli) { Args = args }(Args)
+	// a threshold turns the dithering off
+	Args.Colours, Args.Dither, Args.Theme = photerm.TrueColour, "floyd-steinberg", photerm.DarkTheme
+
+	// one cell of greys, brightening left to right then top to bottom
+	img := image.NewRGBA(image.Rect(0, 0, 2, 4))
+	for i, v := range []uint8{0, 1, 50, 127, 128, 200, 254, 255} {
+		img.SetRGBA(i%2, i/2, grey(v))
+	}
+
+	cases := []struct {
+		name      string
+		threshold int
+		want      screenCell
+	}{
+		// everything brighter than black, inked with the mean of the lit dots
+		{"0", 0, screenCell{'⣾', "38;2;145;145;145", ""}},
+		{"127", 127, screenCell{'⣤', "38;2;209;209;209", ""}},
+		// nothing is brighter than white, so the cell is blank and has no ink
+		{"255", 255, screenCell{BrailleBase, "", ""}},
+	}
+	for _, c := range cases {
+		Args.Threshold = c.threshold
+		if got := replay(t, RenderBraille(img, CharPalette{}, photerm.Region{Right: 2, Btm: 4})).shows(0, 0); got != c.want {
+			t.Errorf("threshold %s: got %c %+v, want %c %+v", c.name, got.glyph, got, c.want.glyph, c.want)
+		}
+	}
+}
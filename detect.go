@@ -0,0 +1,81 @@
+package main
+
+import (
+	"image/color"
+	"os"
+
+	"wombatlord/photerm/photerm_src"
+	"wombatlord/photerm/src/term"
+	"wombatlord/photerm/src/termcap"
+)
+
+// Redirected is what's assumed of output that isn't going to a terminal: text glyphs in
+// true colour for a dark background, as photerm always wrote to files before it looked at
+// the terminal. Whatever terminal it was started from won't be the one reading the file.
+var Redirected = termcap.Caps{Colours: termcap.TrueColour, Unicode: true}
+
+// DetectCaps works out what the terminal can do. The environment gives a first guess,
+// and the terminal is then asked directly.
+func DetectCaps() termcap.Caps {
+	if !term.IsTerminal(os.Stdout) {
+		return Redirected
+	}
+	caps := termcap.FromEnv(os.Getenv)
+	tty, err := term.Open()
+	if err != nil {
+		return caps
+	}
+	defer tty.Close()
+
+	caps, _ = termcap.Query(tty, caps, queryTimeout)
+	return caps
+}
+
+// themeOf returns the theme for glyphs drawn on the background bg. An unknown
+// background, with no alpha, is taken to be dark as most terminals are.
+func themeOf(bg color.RGBA) photerm.Theme {
+	if bg.A != 0 && brightnessOf(bg) >= 128 {
+		return photerm.LightTheme
+	}
+	return photerm.DarkTheme
+}
+
+// ApplyCaps settles every option of Args left on auto using the capabilities of the terminal.
+// Anything picked explicitly on the command line is left alone.
+func ApplyCaps(caps termcap.Caps) {
+	if Args.Renderer == photerm.AutoRenderer {
+		switch {
+		case caps.Kitty:
+			Args.Renderer = photerm.Kitty
+		// a terminal owning up to sixel beats the environment's guess at iTerm
+		case caps.Sixel && caps.Asked:
+			Args.Renderer = photerm.Sixel
+		case caps.ITerm:
+			Args.Renderer = photerm.ITerm
+		case caps.Sixel:
+			Args.Renderer = photerm.Sixel
+		default:
+			Args.Renderer = photerm.Glyph
+		}
+	}
+
+	if Args.Colours == photerm.AutoColours {
+		switch caps.Colours {
+		case termcap.TrueColour:
+			Args.Colours = photerm.TrueColour
+		case termcap.Colours256:
+			Args.Colours = photerm.Colours256
+		default:
+			Args.Colours = photerm.Colours16
+		}
+	}
+
+	if Args.Theme == photerm.AutoTheme {
+		Args.Theme = themeOf(caps.Background)
+	}
+
+	// without unicode the block glyphs come out as garbage, so fall back to plain ASCII
+	if !caps.Unicode && Args.Custom == "" && Charset(Args.Charset) == Normal {
+		Args.Charset = photerm.Charset(ASCIIFY)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"image/color"
+	"testing"
+
+	"wombatlord/photerm/photerm_src"
+	"wombatlord/photerm/src/termcap"
+)
+
+func TestApplyCaps(t *testing.T) {
+	defer func(args photerm.Cli) { Args = args }(Args)
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+
+	cases := []struct {
+		name     string
+		caps     termcap.Caps
+		renderer photerm.Renderer
+		colours  photerm.ColourMode
+		theme    photerm.Theme
+		charset  Charset
+	}{
+		// a file gets the text it always did, whatever terminal photerm was run from
+		{"redirected", Redirected, photerm.Glyph, photerm.TrueColour, photerm.DarkTheme, Normal},
+		{"kitty", termcap.Caps{Kitty: true, Colours: termcap.TrueColour, Unicode: true}, photerm.Kitty, photerm.TrueColour, photerm.DarkTheme, Normal},
+		{"iterm", termcap.Caps{ITerm: true, Colours: termcap.TrueColour, Unicode: true}, photerm.ITerm, photerm.TrueColour, photerm.DarkTheme, Normal},
+		{"sixel guessed", termcap.Caps{ITerm: true, Sixel: true, Colours: termcap.TrueColour, Unicode: true}, photerm.ITerm, photerm.TrueColour, photerm.DarkTheme, Normal},
+		{"sixel answered", termcap.Caps{ITerm: true, Sixel: true, Asked: true, Colours: termcap.TrueColour, Unicode: true}, photerm.Sixel, photerm.TrueColour, photerm.DarkTheme, Normal},
+		{"xterm", termcap.Caps{Colours: termcap.Colours256, Unicode: true, Background: white}, photerm.Glyph, photerm.Colours256, photerm.LightTheme, Normal},
+		{"ascii", termcap.Caps{Colours: termcap.Colours16}, photerm.Glyph, photerm.Colours16, photerm.DarkTheme, ASCIIFY},
+	}
+	for _, c := range cases {
+		Args.Renderer, Args.Colours, Args.Theme = photerm.AutoRenderer, photerm.AutoColours, photerm.AutoTheme
+		Args.Charset, Args.Custom = photerm.Charset(Normal), ""
+		ApplyCaps(c.caps)
+		if Args.Renderer != c.renderer || Args.Colours != c.colours || Args.Theme != c.theme || Charset(Args.Charset) != c.charset {
+			t.Errorf("%s: got %s, %s, %s and charset %d, want %s, %s, %s and charset %d", c.name,
+				Args.Renderer, Args.Colours, Args.Theme, Args.Charset, c.renderer, c.colours, c.theme, c.charset)
+		}
+	}
+
+	// options picked on the command line are left alone
+	Args.Renderer, Args.Colours, Args.Theme = photerm.HalfBlock, photerm.Colours16, photerm.LightTheme
+	ApplyCaps(termcap.Caps{Kitty: true, Colours: termcap.TrueColour})
+	if Args.Renderer != photerm.HalfBlock || Args.Colours != photerm.Colours16 || Args.Theme != photerm.LightTheme {
+		t.Errorf("explicit options changed to %s, %s, %s", Args.Renderer, Args.Colours, Args.Theme)
+	}
+}
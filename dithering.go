@@ -0,0 +1,160 @@
+package main
+
+import (
+	"image"
+	"image/color"
+
+	"wombatlord/photerm/photerm_src"
+	"wombatlord/photerm/src/adjust"
+	"wombatlord/photerm/src/colour"
+	"wombatlord/photerm/src/dither"
+	"wombatlord/photerm/src/tone"
+)
+
+// GlyphPicker chooses the glyph for the pixel at x, y whose colour is rgb
+type GlyphPicker func(x, y int, rgb color.RGBA) rune
+
+// ColourPicker chooses the colour to paint the pixel at x, y whose colour is rgb
+type ColourPicker func(x, y int, rgb color.RGBA) color.RGBA
+
+// Shading is the colour adjustment made to every pixel, nil for none
+var Shading adjust.Adjustment
+
+// ShadingOf returns the colour adjustments picked by args
+func ShadingOf(args photerm.Cli) adjust.Adjustment {
+	return adjust.Chain(
+		adjust.InOKLCH(
+			adjust.Hue(float64(args.HueAngle)),
+			adjust.Saturation(args.Saturate),
+			adjust.Vibrance(args.Vibrance),
+			adjust.Grayscale(args.Grayscale),
+		),
+		adjust.Sepia(args.Sepia).Adjustment(),
+	)
+}
+
+// shade applies the colour adjustments to a pixel
+func shade(rgb color.RGBA) color.RGBA {
+	return Shading.Apply(rgb)
+}
+
+// brightnessOf is the brightness used to index the CharPalette
+func brightnessOf(rgb color.RGBA) uint8 {
+	return color.GrayModel.Convert(rgb).(color.Gray).Y
+}
+
+// brightnessAt is the brightness used to choose the glyph for the pixel at x, y of img, whose colour
+// is rgb. Tone mapped frames carry their own.
+func brightnessAt(img image.Image, x, y int, rgb color.RGBA) uint8 {
+	if frame, ok := img.(*tone.Frame); ok {
+		return frame.Glyph.GrayAt(x, y).Y
+	}
+	return brightnessOf(rgb)
+}
+
+// activePalette returns the palette the colour mode quantizes to, or nil for true colour
+func activePalette() *colour.Palette {
+	switch Args.Colours {
+	case photerm.Colours256:
+		return colour.Xterm256
+	case photerm.Colours16:
+		return colour.ANSI16
+	default:
+		return nil
+	}
+}
+
+// levelsOf returns the distinct glyphs of the palette from darkest to brightest
+func levelsOf(palette CharPalette) (levels []rune) {
+	for i, glyph := range palette {
+		if i == 0 || glyph != palette[i-1] {
+			levels = append(levels, glyph)
+		}
+	}
+	return levels
+}
+
+// dithers reports whether method spreads anything between pixels, rather than quantizing each alone
+func dithers(method dither.Method) bool {
+	return method != nil && method != dither.Method(dither.None{})
+}
+
+// MakeGlyphPicker returns a GlyphPicker for the region r. Without dithering, or when there's only
+// the one glyph to choose from, the palette is indexed by brightness directly, keeping its own
+// spacing of the glyphs. Otherwise the brightness of the whole region is dithered down to the
+// glyphs up front, taking them as evenly spaced.
+func MakeGlyphPicker(img image.Image, palette CharPalette, r photerm.Region, method dither.Method) GlyphPicker {
+	levels := levelsOf(palette)
+	if !dithers(method) || len(levels) < 2 {
+		return func(x, y int, rgb color.RGBA) rune {
+			return palette[brightnessAt(img, x, y, rgb)]
+		}
+	}
+
+	plane := dither.NewPlane(r.Right-r.Left, r.Btm-r.Top, 1)
+	for y := 0; y < plane.H; y++ {
+		for x := 0; x < plane.W; x++ {
+			rgb := color.RGBAModel.Convert(img.At(r.Left+x, r.Top+y)).(color.RGBA)
+			plane.At(x, y)[0] = float64(brightnessAt(img, r.Left+x, r.Top+y, rgb))
+		}
+	}
+
+	// quantize to the index of the nearest glyph, treating the glyphs as evenly spaced
+	step := 255 / float64(len(levels)-1)
+	method.Dither(plane, step, func(px []float64) {
+		level := int(px[0]/step + 0.5)
+		if level < 0 {
+			level = 0
+		} else if level >= len(levels) {
+			level = len(levels) - 1
+		}
+		px[0] = float64(level) * step
+	})
+
+	return func(x, y int, _ color.RGBA) rune {
+		return levels[int(plane.At(x-r.Left, y-r.Top)[0]/step+0.5)]
+	}
+}
+
+// MakeColourPicker returns a ColourPicker for the region r. In true colour, or without dithering,
+// the colour is just shaded and Ink finds the nearest palette colour. When quantizing to a palette the shaded region is dithered
+// to the palette up front so Ink finds every colour already in the palette.
+func MakeColourPicker(img image.Image, r photerm.Region, method dither.Method) ColourPicker {
+	palette := activePalette()
+	if !dithers(method) || palette == nil {
+		return func(_, _ int, rgb color.RGBA) color.RGBA {
+			return shade(rgb)
+		}
+	}
+
+	plane := dither.NewPlane(r.Right-r.Left, r.Btm-r.Top, 3)
+	for y := 0; y < plane.H; y++ {
+		for x := 0; x < plane.W; x++ {
+			rgb := shade(color.RGBAModel.Convert(img.At(r.Left+x, r.Top+y)).(color.RGBA))
+			px := plane.At(x, y)
+			px[0], px[1], px[2] = float64(rgb.R), float64(rgb.G), float64(rgb.B)
+		}
+	}
+
+	method.Dither(plane, palette.Step(), func(px []float64) {
+		c := color.RGBA{R: clampByte(px[0]), G: clampByte(px[1]), B: clampByte(px[2]), A: 255}
+		c = palette.Colour(palette.Nearest(c))
+		px[0], px[1], px[2] = float64(c.R), float64(c.G), float64(c.B)
+	})
+
+	return func(x, y int, _ color.RGBA) color.RGBA {
+		px := plane.At(x-r.Left, y-r.Top)
+		return color.RGBA{R: uint8(px[0]), G: uint8(px[1]), B: uint8(px[2]), A: 255}
+	}
+}
+
+// clampByte rounds a float sample to the nearest byte
+func clampByte(f float64) uint8 {
+	if f <= 0 {
+		return 0
+	}
+	if f >= 255 {
+		return 255
+	}
+	return uint8(f + 0.5)
+}
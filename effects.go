@@ -0,0 +1,35 @@
+package main
+
+import (
+	"image"
+	"image/color"
+
+	"wombatlord/photerm/photerm_src"
+	"wombatlord/photerm/src/adjust"
+)
+
+// AppendEffectsStep attaches the animated colour effects picked by Args to the image buffer, if any
+// were. Each frame is shaded for the moment it's shown, as timed by the timing step.
+func AppendEffectsStep(in <-chan image.Image) <-chan image.Image {
+	effects := adjust.Effects{HueCycle: Args.HueCycle, Rainbow: Args.Rainbow, Pulse: Args.Pulse}
+	if effects.None() {
+		return in
+	}
+	return photerm.AppendMappingStep(in, func(f *photerm.Frame) image.Image {
+		return shadeImage(f.Image, effects.At(f.Time, f.Bounds().Dx()))
+	})
+}
+
+// shadeImage returns a copy of img with every pixel shaded by shader, which is given
+// positions from the top left of the image
+func shadeImage(img image.Image, shader adjust.Shader) *image.RGBA {
+	b := img.Bounds()
+	out := image.NewRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			rgb := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+			out.SetRGBA(x, y, shader(x-b.Min.X, y-b.Min.Y, rgb))
+		}
+	}
+	return out
+}
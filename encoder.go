@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"io"
+	"strings"
+
+	"wombatlord/photerm/photerm_src"
+	"wombatlord/photerm/src/util"
+)
+
+// Cell is a single character cell of a rendered frame.
+type Cell struct {
+	Glyph rune
+	// FG is only painted when HasFG is set, cells without a visible foreground
+	// leave it alone to save on escape sequences
+	FG    color.RGBA
+	HasFG bool
+	// BG is only painted when HasBG is set, otherwise the terminal's default shows through
+	BG    color.RGBA
+	HasBG bool
+	// Transparent cells aren't drawn at all, leaving whatever the terminal showed there
+	Transparent bool
+}
+
+// Grid is a rendered frame, row by row from the top
+type Grid [][]Cell
+
+// Pen remembers the colours last sent to the terminal so the encoder only sends a colour when
+// it changes. Colours within Tolerance of the current one on every channel count as unchanged.
+type Pen struct {
+	Tolerance int
+
+	fg, bg       color.RGBA
+	fgInk, bgInk []byte
+	hasBG        bool
+
+	// verbose sends every colour regardless, which is how frames used to be encoded
+	verbose bool
+}
+
+// Reset forgets the colours, so the next cell sends its colours in full
+func (p *Pen) Reset() {
+	p.fgInk, p.bgInk, p.hasBG = nil, nil, false
+}
+
+// near reports whether a and b are within the pen's tolerance of each other
+func (p *Pen) near(a, b color.RGBA) bool {
+	return absDiff(a.R, b.R) <= p.Tolerance && absDiff(a.G, b.G) <= p.Tolerance && absDiff(a.B, b.B) <= p.Tolerance
+}
+
+func absDiff(a, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
+
+// paint appends the ink for c to line, unless the terminal already has it, or near enough.
+// The last ink is compared too, as colours that differ may quantize to the same palette entry.
+func (p *Pen) paint(line []byte, c color.RGBA, painter Painter, last *color.RGBA, lastInk *[]byte) []byte {
+	if !p.verbose && *lastInk != nil && p.near(c, *last) {
+		return line
+	}
+	ink := Ink(c, painter)
+	if !p.verbose && bytes.Equal(ink, *lastInk) {
+		return line
+	}
+	*last, *lastInk = c, ink
+	return append(line, ink...)
+}
+
+// Encode appends the escape sequences and glyph of a cell to line
+func (p *Pen) Encode(line []byte, cell Cell) []byte {
+	if cell.HasFG {
+		line = p.paint(line, cell.FG, Foreground, &p.fg, &p.fgInk)
+	}
+	if cell.HasBG {
+		line = p.paint(line, cell.BG, Background, &p.bg, &p.bgInk)
+		p.hasBG = true
+	} else if p.hasBG {
+		line = append(line, DefaultBackground...)
+		p.bgInk, p.hasBG = nil, false
+	}
+	return append(line, string(cell.Glyph)...)
+}
+
+// EndLine appends whatever the line needs before the newline. A background left on
+// would bleed into the new line if the terminal scrolls, so it's switched off.
+func (p *Pen) EndLine(line []byte) []byte {
+	if p.hasBG {
+		line = append(line, DefaultBackground...)
+		p.bgInk, p.hasBG = nil, false
+	}
+	return line
+}
+
+// EncodeGrid returns the printable lines of the grid. The pen is reset first, so nothing
+// is assumed about the colours the terminal was left with by the previous frame.
+func EncodeGrid(grid Grid, pen *Pen) (frameLines []string) {
+	pen.Reset()
+	frameLines = make([]string, 0, len(grid))
+	for _, row := range grid {
+		line := []byte{}
+		skip := 0
+		for _, cell := range row {
+			if cell.Transparent {
+				skip++
+				continue
+			}
+			if skip > 0 {
+				line = append(line, util.MoveRight(skip)...)
+				skip = 0
+			}
+			line = pen.Encode(line, cell)
+		}
+		frameLines = append(frameLines, string(pen.EndLine(line)))
+	}
+	return frameLines
+}
+
+// GridEncoder adapts a FrameRenderer into a FrameEncoder, compressing colours with the tolerance
+// selected by the Tolerance Arg.
+func GridEncoder(render FrameRenderer) FrameEncoder {
+	return func(writer io.Writer, img image.Image, palette CharPalette, r photerm.Region) (int, error) {
+		frame := EncodeGrid(render(img, palette, r), &Pen{Tolerance: Args.Tolerance})
+		_, err := io.WriteString(writer, strings.Join(frame, "\n"))
+		return len(frame), err
+	}
+}
+
+// PaintedBackground adapts a FrameRenderer to paint the Backdrop behind every cell that would
+// otherwise show the terminal's own background, so the frame looks the same on any theme.
+func PaintedBackground(render FrameRenderer) FrameRenderer {
+	return func(img image.Image, palette CharPalette, r photerm.Region) Grid {
+		grid := render(img, palette, r)
+		for _, row := range grid {
+			for x := range row {
+				if !row[x].HasBG && !row[x].Transparent {
+					row[x].BG, row[x].HasBG = Backdrop, true
+				}
+			}
+		}
+		return grid
+	}
+}
+
+// same reports whether the terminal would show b the same as a, with colours compared
+// within the pen's tolerance
+func (p *Pen) same(a, b Cell) bool {
+	if a.Transparent || b.Transparent {
+		return a.Transparent == b.Transparent
+	}
+	return a.Glyph == b.Glyph &&
+		a.HasFG == b.HasFG && (!a.HasFG || p.near(a.FG, b.FG)) &&
+		a.HasBG == b.HasBG && (!a.HasBG || p.near(a.BG, b.BG))
+}
+
+// EncodeDiff returns the bytes that turn prev into next on screen, starting from the top left
+// cell of the frame and leaving the cursor on its last row, as a full frame would.
+// Cells of prev are overwritten with whatever was sent, so colours can't creep by less than
+// the tolerance each frame. When the dimensions differ or more than half of the cells changed
+// the whole grid is sent instead, and full is true. Cells that turn transparent are blanked.
+func EncodeDiff(prev, next Grid, pen *Pen) (out []byte, full bool) {
+	changed, ok := countChanges(prev, next, pen)
+	if !ok {
+		return []byte(strings.Join(EncodeGrid(next, pen), "\n")), true
+	}
+	if changed*2 > cellCount(next) {
+		return []byte(strings.Join(EncodeGrid(erased(prev, next), pen), "\n")), true
+	}
+	row, col := 0, 0
+	for y, cells := range next {
+		for x, cell := range cells {
+			if pen.same(prev[y][x], cell) {
+				continue
+			}
+			if y > row {
+				// a carriage return first, the cursor may be waiting to wrap past the last column
+				out = append(out, '\r')
+				out = append(out, util.MoveDown(y-row)...)
+				row, col = y, 0
+			}
+			if x < col {
+				out = append(out, '\r')
+				col = 0
+			}
+			if x > col {
+				out = append(out, util.MoveRight(x-col)...)
+			}
+			if cell.Transparent {
+				out = pen.Encode(out, blank)
+			} else {
+				out = pen.Encode(out, cell)
+			}
+			prev[y][x] = cell
+			col = x + 1
+		}
+	}
+	if last := len(next) - 1; last > row {
+		out = append(out, util.MoveDown(last-row)...)
+	}
+	return pen.EndLine(out), false
+}
+
+// blank is drawn over a cell that turned transparent, as whatever was under it is long gone
+var blank = Cell{Glyph: ' '}
+
+// erased returns a copy of next with the cells that turned transparent since prev blanked
+func erased(prev, next Grid) Grid {
+	out := make(Grid, len(next))
+	for y, row := range next {
+		out[y] = append([]Cell(nil), row...)
+		for x, cell := range row {
+			if cell.Transparent && !prev[y][x].Transparent {
+				out[y][x] = blank
+			}
+		}
+	}
+	return out
+}
+
+// countChanges returns how many cells differ between the grids, or false if they
+// aren't the same shape
+func countChanges(prev, next Grid, pen *Pen) (changed int, ok bool) {
+	if len(prev) != len(next) {
+		return 0, false
+	}
+	for y := range next {
+		if len(prev[y]) != len(next[y]) {
+			return 0, false
+		}
+		for x := range next[y] {
+			if !pen.same(prev[y][x], next[y][x]) {
+				changed++
+			}
+		}
+	}
+	return changed, true
+}
+
+func cellCount(grid Grid) (n int) {
+	for _, row := range grid {
+		n += len(row)
+	}
+	return n
+}
+
+// DiffEncoder adapts a FrameRenderer into a FrameEncoder for animation, which remembers the
+// previous frame and sends only the cells that changed. The pen is kept between frames too,
+// as nothing but cursor movement is sent between them.
+func DiffEncoder(render FrameRenderer) FrameEncoder {
+	var prev Grid
+	pen := &Pen{Tolerance: Args.Tolerance}
+	return func(writer io.Writer, img image.Image, palette CharPalette, r photerm.Region) (int, error) {
+		next := render(img, palette, r)
+		out, full := EncodeDiff(prev, next, pen)
+		if full {
+			prev = next
+		}
+		_, err := writer.Write(out)
+		return len(next), err
+	}
+}
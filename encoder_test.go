@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+
+	"wombatlord/photerm/photerm_src"
+)
+
+// screenCell is what a terminal shows in a cell. The colours are kept as the SGR parameters
+// that set them, empty for the terminal's default.
+type screenCell struct {
+	glyph  rune
+	fg, bg string
+}
+
+// screen is just enough of a terminal to replay what the encoders send: glyphs, SGR colours,
+// carriage returns, newlines and the cursor movement. Like a terminal, the colours last set
+// carry on from one frame to the next.
+type screen struct {
+	t        *testing.T
+	cells    [][]screenCell
+	row, col int
+	fg, bg   string
+}
+
+func newScreen(t *testing.T, rows, cols int) *screen {
+	s := &screen{t: t, cells: make([][]screenCell, rows)}
+	for y := range s.cells {
+		s.cells[y] = make([]screenCell, cols)
+		for x := range s.cells[y] {
+			s.cells[y][x].glyph = ' '
+		}
+	}
+	return s
+}
+
+func (s *screen) Write(p []byte) (int, error) {
+	for rest := string(p); rest != ""; {
+		switch {
+		case strings.HasPrefix(rest, CSI):
+			end := strings.IndexAny(rest, "ABCm")
+			if end < 0 {
+				s.t.Fatalf("unfinished escape sequence %q", rest)
+			}
+			s.escape(rest[len(CSI):end], rest[end])
+			rest = rest[end+1:]
+		case rest[0] == '\r':
+			s.col, rest = 0, rest[1:]
+		case rest[0] == '\n':
+			s.row, s.col, rest = s.row+1, 0, rest[1:]
+		default:
+			r, size := []rune(rest)[0], len(string([]rune(rest)[0]))
+			s.cells[s.row][s.col] = screenCell{glyph: r, fg: s.fg, bg: s.bg}
+			s.col, rest = s.col+1, rest[size:]
+		}
+	}
+	return len(p), nil
+}
+
+func (s *screen) escape(params string, final byte) {
+	n, _ := strconv.Atoi(params)
+	switch final {
+	case 'A':
+		s.row -= n
+	case 'B':
+		s.row += n
+	case 'C':
+		s.col += n
+	case 'm':
+		code, _ := strconv.Atoi(strings.SplitN(params, ";", 2)[0])
+		switch {
+		case code == 0:
+			s.fg, s.bg = "", ""
+		case code == 49:
+			s.bg = ""
+		case code == 38 || code >= 30 && code <= 37 || code >= 90 && code <= 97:
+			s.fg = params
+		case code == 48 || code >= 40 && code <= 47 || code >= 100 && code <= 107:
+			s.bg = params
+		default:
+			s.t.Fatalf("unknown SGR %q", params)
+		}
+	}
+}
+
+// shows returns how cell y, x of the frame looks. A blank cell's foreground can't be seen.
+func (s *screen) shows(y, x int) screenCell {
+	c := s.cells[y][x]
+	if c.glyph == ' ' {
+		c.fg = ""
+	}
+	return c
+}
+
+// replay returns a fresh screen showing grid encoded whole
+func replay(t *testing.T, grid Grid) *screen {
+	s := newScreen(t, len(grid), len(grid[0]))
+	fmt.Fprint(s, strings.Join(EncodeGrid(grid, &Pen{}), "\n"))
+	return s
+}
+
+// randomFrames returns frames that each change a few cells of the last, with the odd frame
+// changing most of them, from a handful of colours that share palette entries
+func randomFrames(rng *rand.Rand, count, rows, cols int) []Grid {
+	colours := []color.RGBA{{R: 255}, {R: 250, G: 5}, {G: 200, B: 40}, {B: 255}, {R: 128, G: 128, B: 128}}
+	cell := func() Cell {
+		switch rng.Intn(6) {
+		case 0:
+			return Cell{Transparent: true}
+		case 1:
+			return Cell{Glyph: '▀', FG: colours[rng.Intn(len(colours))], HasFG: true}
+		default:
+			return Cell{Glyph: []rune("#@▄")[rng.Intn(3)], FG: colours[rng.Intn(len(colours))], HasFG: true,
+				BG: colours[rng.Intn(len(colours))], HasBG: true}
+		}
+	}
+
+	frames := make([]Grid, count)
+	for i := range frames {
+		frames[i] = make(Grid, rows)
+		for y := range frames[i] {
+			frames[i][y] = make([]Cell, cols)
+			for x := range frames[i][y] {
+				if i == 0 || rng.Intn(8) == 0 || i%5 == 0 {
+					frames[i][y][x] = cell()
+				} else {
+					frames[i][y][x] = frames[i-1][y][x]
+				}
+			}
+		}
+	}
+	return frames
+}
+
+func TestDiffEncoderReplays(t *testing.T) {
+	defer func(args photerm.Cli) { Args = args }(Args)
+	const rows, cols = 6, 10
+
+	for _, colours := range []photerm.ColourMode{photerm.TrueColour, photerm.Colours256, photerm.Colours16} {
+		Args.Colours, Args.Tolerance = colours, 0
+		frames := randomFrames(rand.New(rand.NewSource(1)), 30, rows, cols)
+		next := 0
+		encode := DiffEncoder(func(image.Image, CharPalette, photerm.Region) Grid {
+			// copied, as the encoder keeps the grid and writes what it sends into it
+			grid := make(Grid, len(frames[next]))
+			for y := range grid {
+				grid[y] = append([]Cell(nil), frames[next][y]...)
+			}
+			return grid
+		})
+
+		// the line above the frame is left free for the animate hook to seek back to
+		played := newScreen(t, rows+1, cols)
+		played.row = 1
+		for ; next < len(frames); next++ {
+			printed, err := encode(played, nil, CharPalette{}, photerm.Region{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = frameEndHooks.Animate(played, printed); err != nil {
+				t.Fatal(err)
+			}
+
+			// a fresh screen shows the frame encoded whole, transparent cells left blank
+			whole := replay(t, frames[next])
+			for y := 0; y < rows; y++ {
+				for x := 0; x < cols; x++ {
+					if got, want := played.shows(y+1, x), whole.shows(y, x); got != want {
+						t.Fatalf("%s colour, frame %d, cell %d, %d: got %+v, want %+v", colours, next, y, x, got, want)
+					}
+				}
+			}
+			if played.row != 1 || played.col != 0 {
+				t.Fatalf("%s colour, frame %d: the hook left the cursor at %d, %d", colours, next, played.row, played.col)
+			}
+		}
+	}
+}
+
+func TestEncodeDiffSendsOnlyChanges(t *testing.T) {
+	red, blue := color.RGBA{R: 255}, color.RGBA{B: 255}
+	hash, at := Cell{Glyph: '#', FG: red, HasFG: true}, Cell{Glyph: '@', FG: blue, HasFG: true}
+	still := func() Grid { return Grid{{hash, hash, hash}, {hash, hash, hash}} }
+	moved := Grid{{hash, hash, hash}, {hash, at, hash}}
+
+	pen := &Pen{}
+	shown := still()
+	EncodeGrid(shown, pen)
+	out, full := EncodeDiff(shown, moved, pen)
+	if full {
+		t.Fatal("one changed cell sent the whole frame")
+	}
+	if want := "\r" + CSI + "1B" + CSI + "1C" + string(Ink(blue, Foreground)) + "@"; string(out) != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+
+	// nothing changed, but the cursor still finishes on the last row
+	if out, _ = EncodeDiff(shown, moved, pen); string(out) != CSI+"1B" {
+		t.Errorf("an unchanged frame sent %q", out)
+	}
+
+	// the pen was left blue by the last frame, so going back has to send the red
+	out, _ = EncodeDiff(shown, still(), pen)
+	if want := "\r" + CSI + "1B" + CSI + "1C" + string(Ink(red, Foreground)) + "#"; string(out) != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+}
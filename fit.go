@@ -0,0 +1,138 @@
+package main
+
+import (
+	"image"
+	"os"
+	"sync"
+
+	"wombatlord/photerm/photerm_src"
+	"wombatlord/photerm/src/term"
+)
+
+// TerminalSize returns the size of the terminal on stdout, asking the terminal for the size
+// of its cells when the kernel doesn't know. ok is false when stdout isn't a terminal.
+func TerminalSize() (size term.Size, ok bool) {
+	if !term.IsTerminal(os.Stdout) {
+		return size, false
+	}
+	size, err := term.GetSize(os.Stdout)
+	if err != nil || size.Cols == 0 || size.Rows == 0 {
+		return size, false
+	}
+	if size.CellWidth > 0 && size.CellHeight > 0 {
+		return size, true
+	}
+
+	tty, err := term.Open()
+	if err != nil {
+		return size, true
+	}
+	defer tty.Close()
+	size, _ = term.QueryCellSize(tty, size, queryTimeout)
+	return size, true
+}
+
+// Scales are the ScaleFactors frames are scaled with, settled by FitToTerminal. On a terminal
+// they're a LiveFit, following it as it's resized.
+var Scales photerm.ScaleFactors
+
+// boxOf returns the cells of the terminal an image can fill. One row is kept back,
+// for the prompt after a still or the line above an animation.
+func boxOf(size term.Size) (cols, rows int) {
+	return size.Cols, size.Rows - 1
+}
+
+// FitToTerminal settles the scale and squash of Args when they were left unset. Squash corrects
+// for cells being taller than they are wide, and without a scale, cols or rows the image is
+// fitted to the terminal. The pixel backends are told the real size of a cell too.
+// When stdout isn't a terminal, both are 1 as they always were.
+func FitToTerminal() {
+	size, ok := TerminalSize()
+	if !ok {
+		if Args.Squash == photerm.NotSet {
+			Args.Squash = 1
+		}
+		if Args.Scale == photerm.NotSet {
+			Args.Scale = 1
+		}
+		Scales = Args
+		return
+	}
+
+	if size.CellWidth > 0 && size.CellHeight > 0 {
+		photerm.CellWidth, photerm.CellHeight = size.CellWidth, size.CellHeight
+	}
+	if Args.Squash == photerm.NotSet {
+		Args.Squash = float64(photerm.CellHeight) / float64(photerm.CellWidth)
+	}
+	autoFit := Args.Scale == photerm.NotSet && Args.Cols == photerm.NotSet && Args.Rows == photerm.NotSet
+	if autoFit {
+		Args.Cols, Args.Rows = boxOf(size)
+	}
+	if Args.Scale == photerm.NotSet {
+		Args.Scale = 1
+	}
+	Scales = NewLiveFit(Args, size, autoFit)
+}
+
+// LiveFit is the ScaleFactors of output to a terminal, which Refit keeps up with the size
+// of the terminal. Frames fitted to the terminal fill it whatever its size. Frames with a scale
+// or box from the command line keep it, until the terminal is resized, from then on they're
+// shrunk when they wouldn't fit. It's safe for concurrent use.
+type LiveFit struct {
+	photerm.ScaleFactors
+
+	mu sync.Mutex
+	// cols and rows are the box of the terminal
+	cols, rows int
+	// auto is set when frames are fitted to the terminal
+	auto    bool
+	resized bool
+}
+
+// NewLiveFit starts a LiveFit from sf on a terminal of size.
+func NewLiveFit(sf photerm.ScaleFactors, size term.Size, auto bool) *LiveFit {
+	f := &LiveFit{ScaleFactors: sf, auto: auto}
+	f.cols, f.rows = boxOf(size)
+	return f
+}
+
+func (f *LiveFit) GetFit() (cols, rows int) {
+	if !f.auto {
+		return f.ScaleFactors.GetFit()
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.cols, f.rows
+}
+
+func (f *LiveFit) GetLimit() (cols, rows int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.auto || !f.resized {
+		return photerm.NotSet, photerm.NotSet
+	}
+	return f.cols, f.rows
+}
+
+// Zoom compares the size img is scaled to now with the size it was scaled to from the start.
+func (f *LiveFit) Zoom(img image.Image) float64 {
+	w, _ := photerm.OutputDimsOf(f, img)
+	start, _ := photerm.OutputDimsOf(f.ScaleFactors, img)
+	if start == 0 {
+		return 1
+	}
+	return float64(w) / float64(start)
+}
+
+// Refit sets the box to the current size of the terminal.
+func (f *LiveFit) Refit() {
+	size, err := term.GetSize(os.Stdout)
+	if err != nil || size.Cols == 0 || size.Rows == 0 {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cols, f.rows = boxOf(size)
+	f.resized = true
+}
@@ -6,7 +6,6 @@ require (
 	github.com/alexflint/go-arg v1.4.3
 	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0
 	github.com/nfnt/resize v0.0.0-20180221191011-83c6a9932646
-	github.com/ungerik/go3d v0.0.0-20220309204530-55ced4bcb334
 	golang.org/x/image v0.0.0-20220722155232-062f8c9fd539
 )
 
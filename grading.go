@@ -0,0 +1,25 @@
+package main
+
+import (
+	"image"
+	"image/color"
+
+	"wombatlord/photerm/photerm_src"
+	"wombatlord/photerm/src/lut"
+)
+
+// Grade is the LUT loaded from the LUT Arg, nil for none
+var Grade *lut.LUT
+
+// AppendGradingStep attaches grading every frame with Grade to the image buffer, if a LUT was loaded.
+func AppendGradingStep(in <-chan image.Image) <-chan image.Image {
+	if Grade == nil {
+		return in
+	}
+	interp := lut.Interpolations[Args.LutInterp]
+	return photerm.AppendMappingStep(in, func(f *photerm.Frame) image.Image {
+		return shadeImage(f.Image, func(_, _ int, c color.RGBA) color.RGBA {
+			return Grade.Apply(c, interp)
+		})
+	})
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"image"
+	"image/color"
+
+	"wombatlord/photerm/photerm_src"
+	"wombatlord/photerm/src/dither"
+)
+
+// UpperHalf is the glyph used by the half block renderer. The foreground paints
+// the top pixel and the background paints the bottom one.
+const UpperHalf = '▀'
+
+// LowerHalf paints just the bottom pixel, when the top one is skipped for being transparent.
+const LowerHalf = '▄'
+
+// DefaultBackground resets the background to whatever the terminal uses.
+const DefaultBackground = "\u001b[49m"
+
+// RenderHalfBlocks returns the cells of a single frame, packing two vertically adjacent
+// pixels into each cell. The palette is ignored as every cell uses the same glyph.
+func RenderHalfBlocks(img image.Image, _ CharPalette, r photerm.Region) (grid Grid) {
+	grid = make(Grid, 0, (r.Btm-r.Top+1)/2)
+	colourAt := MakeColourPicker(img, r, dither.Methods[Args.Dither])
+
+	// step over the rows two at a time, the top pixel is the foreground...
+	for y := r.Top; y < r.Btm; y += 2 {
+		row := make([]Cell, 0, r.Right-r.Left)
+		for x := r.Left; x < r.Right; x++ {
+			top := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+			cell := Cell{Glyph: UpperHalf, HasFG: true}
+			cell.FG = colourAt(x, y, top)
+
+			// ...and the bottom pixel is the background. An odd height leaves
+			// the last row without a bottom pixel, so let the terminal fill it
+			if y+1 < r.Btm {
+				btm := color.RGBAModel.Convert(img.At(x, y+1)).(color.RGBA)
+				if !skipped(btm) {
+					cell.BG = colourAt(x, y+1, btm)
+					cell.HasBG = true
+				}
+			}
+
+			// a transparent top pixel leaves the bottom one to the lower half block
+			if skipped(top) {
+				cell = Cell{Glyph: LowerHalf, FG: cell.BG, HasFG: cell.HasBG, Transparent: !cell.HasBG}
+			}
+			row = append(row, cell)
+		}
+		grid = append(grid, row)
+	}
+	return grid
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"wombatlord/photerm/photerm_src"
+)
+
+func TestRenderHalfBlocks(t *testing.T) {
+	defer func(args photerm.Cli) { Args = args }(Args)
+	Args.Colours, Args.Dither, Args.Alpha = photerm.TrueColour, "none", photerm.Skip
+
+	red, green, blue := color.RGBA{R: 255, A: 255}, color.RGBA{G: 255, A: 255}, color.RGBA{B: 255, A: 255}
+	// three rows, so the last row of cells has no bottom pixels
+	img := image.NewRGBA(image.Rect(0, 0, 3, 3))
+	img.SetRGBA(0, 0, red)
+	img.SetRGBA(0, 1, blue)
+	img.SetRGBA(1, 1, blue)
+	img.SetRGBA(2, 0, red)
+	img.SetRGBA(0, 2, green)
+	grid := RenderHalfBlocks(img, CharPalette{}, photerm.Region{Right: 3, Btm: 3})
+	if len(grid) != 2 {
+		t.Fatalf("got %d rows, want 2", len(grid))
+	}
+
+	const fgRed, fgGreen, fgBlue, bgBlue = "38;2;255;0;0", "38;2;0;255;0", "38;2;0;0;255", "48;2;0;0;255"
+	cases := []struct {
+		name string
+		y, x int
+		want screenCell
+	}{
+		{"both pixels", 0, 0, screenCell{UpperHalf, fgRed, bgBlue}},
+		// a transparent top turns the block over, so the terminal's background shows above
+		{"transparent top", 0, 1, screenCell{LowerHalf, fgBlue, ""}},
+		{"transparent bottom", 0, 2, screenCell{UpperHalf, fgRed, ""}},
+		{"odd height", 1, 0, screenCell{UpperHalf, fgGreen, ""}},
+		{"transparent", 1, 1, screenCell{' ', "", ""}},
+	}
+	shown := replay(t, grid)
+	for _, c := range cases {
+		if got := shown.shows(c.y, c.x); got != c.want {
+			t.Errorf("%s: got %+v, want %+v", c.name, got, c.want)
+		}
+	}
+	if !grid[1][1].Transparent || grid[0][1].Transparent {
+		t.Error("only the cell with neither pixel should be transparent")
+	}
+}
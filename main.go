@@ -6,8 +6,6 @@ import (
 	"fmt"
 	"image"
 	"image/color"
-	_ "image/jpeg"
-	_ "image/png"
 	"io"
 	"log"
 	"os"
@@ -15,7 +13,11 @@ import (
 	"time"
 
 	"wombatlord/photerm/photerm_src"
-	"wombatlord/photerm/src/rotato"
+	"wombatlord/photerm/src/colour"
+	"wombatlord/photerm/src/dither"
+	"wombatlord/photerm/src/lut"
+	"wombatlord/photerm/src/term"
+	"wombatlord/photerm/src/tone"
 	"wombatlord/photerm/src/util"
 
 	"github.com/alexflint/go-arg"
@@ -49,6 +51,36 @@ func RGB(r, g, b byte, p Painter) []byte {
 	)
 }
 
+// Indexed paints the string with the palette colour at code, as used by 256 colour terminals
+func Indexed(code uint8, p Painter) []byte {
+	return append(append(append([]byte(p), []byte("5;")...), numeralCache[code]...), 'm')
+}
+
+// ANSI paints the string with one of the basic 16 colours. These predate the 38/48 painters
+// so the code carries the layer instead: 30-37 and 90-97 for foreground, 40-47 and 100-107 for background.
+func ANSI(code uint8, p Painter) []byte {
+	base := 30
+	if p == Background {
+		base = 40
+	}
+	if code >= 8 {
+		base, code = base+60, code-8
+	}
+	return append(append([]byte(CSI), numeralCache[base+int(code)]...), 'm')
+}
+
+// Ink paints the string with the colour c, quantized to suit the selected colour mode
+func Ink(c color.RGBA, p Painter) []byte {
+	switch Args.Colours {
+	case photerm.Colours256:
+		return Indexed(colour.Xterm256.Nearest(c), p)
+	case photerm.Colours16:
+		return ANSI(colour.ANSI16.Nearest(c), p)
+	default:
+		return RGB(c.R, c.G, c.B, p)
+	}
+}
+
 func MoveCursorUp(n int) string {
 	return fmt.Sprintf("\n%s%dA", CSI, n)
 }
@@ -75,6 +107,15 @@ var Charsets = [4][]string{
 
 type CharPalette [256]rune
 
+// Inverted returns the palette with its brightness turned around, so the densest glyphs
+// go to the darkest pixels as suits a light background.
+func (p CharPalette) Inverted() (inv CharPalette) {
+	for i, glyph := range p {
+		inv[len(p)-1-i] = glyph
+	}
+	return inv
+}
+
 // MakeCharPalette takes an arbitrary number of string arguments and concatenates (and stretches, if necessary)
 // them together into a CharPalette
 func MakeCharPalette(glyphs ...string) CharPalette {
@@ -87,11 +128,70 @@ func MakeCharPalette(glyphs ...string) CharPalette {
 var Args photerm.Cli
 var fc photerm.FrameCache
 
+// FrameRenderer turns the region r of a frame into a Grid of cells.
+type FrameRenderer func(img image.Image, palette CharPalette, r photerm.Region) Grid
+
+// FrameEncoder writes the region r of a frame to the writer and returns the number
+// of lines printed, which is what the frame end hook needs to seek back over.
+type FrameEncoder func(writer io.Writer, img image.Image, palette CharPalette, r photerm.Region) (lines int, err error)
+
+// Backend is everything FOutFromBuf needs to put frames of a renderer on screen
+type Backend struct {
+	Encode FrameEncoder
+	Hooks  FrameEndHooks
+	// Render is set for the renderers that produce cells, so animation can send only what changed
+	Render FrameRenderer
+	// Animation is set for the backends that send animation frames differently to stills
+	Animation FrameEncoder
+	// Cleanup is set for the backends that leave something on the terminal after an animation
+	// has been played, it's written as the playback session ends
+	Cleanup func(writer io.Writer) error
+}
+
+// Backends is the mapping of renderer name to implementation
+// indexed by Renderer Arg.
+var Backends = map[photerm.Renderer]Backend{
+	photerm.Glyph:     {GridEncoder(RenderFrame), frameEndHooks, RenderFrame, nil, nil},
+	photerm.HalfBlock: {GridEncoder(RenderHalfBlocks), frameEndHooks, RenderHalfBlocks, nil, nil},
+	photerm.Braille:   {GridEncoder(RenderBraille), frameEndHooks, RenderBraille, nil, nil},
+	photerm.Symbols:   {GridEncoder(RenderSymbols), frameEndHooks, RenderSymbols, nil, nil},
+	photerm.Sixel:     {EncodeSixel, savedCursorHooks, nil, nil, nil},
+	photerm.Kitty:     {KittyEncoder(0), kittyFrameEndHooks, nil, KittyEncoder(kittyAnimationID), DeleteKittyAnimation},
+	photerm.ITerm:     {EncodeITerm, savedCursorHooks, nil, nil, nil},
+}
+
+// AnimationEncoder returns the FrameEncoder for animating with the backend. When the backend
+// renders cells only the ones that changed since the last frame are sent, unless Redraw is set.
+func AnimationEncoder(backend Backend) FrameEncoder {
+	if backend.Animation != nil {
+		return backend.Animation
+	}
+	if backend.Render == nil || Args.Redraw {
+		return backend.Encode
+	}
+	return DiffEncoder(backend.Render)
+}
+
+// BackendFor looks up the Backend selected by name, falling back to RenderFrame.
+// Backends that render cells paint the Backdrop behind them when PaintBG is set.
+func BackendFor(name photerm.Renderer) Backend {
+	backend, ok := Backends[name]
+	if !ok {
+		backend = Backends[photerm.Glyph]
+	}
+	if Args.PaintBG && backend.Render != nil {
+		render := PaintedBackground(backend.Render)
+		backend.Encode, backend.Render = GridEncoder(render), render
+	}
+	return backend
+}
+
 // GetFpsLimiter returns an adaptor locked to the provided FPS
 // that takes the original unlimited buffer and a new buffer to be populated with one
 // frame per tick where a tick is 1/fps seconds.
 func GetFpsLimiter(fps int) func(in <-chan image.Image, out chan<- image.Image) {
 	fpsLimiter := func(in <-chan image.Image, out chan<- image.Image) {
+		defer photerm.Guard()
 		ticker := time.NewTicker(time.Second / time.Duration(fps))
 		for range ticker.C {
 			frame, frameOk := <-in
@@ -129,43 +229,97 @@ var frameEndHooks = FrameEndHooks{
 	},
 }
 
+// AppendFrameSteps attaches the steps that come between scaling and rendering:
+// compositing, grading, the animated effects and tone mapping.
+func AppendFrameSteps(in <-chan image.Image) <-chan image.Image {
+	return AppendToningStep(AppendEffectsStep(AppendGradingStep(AppendCompositingStep(in))))
+}
+
+// nominalFPS is the frame rate frames without a delay of their own are timed by when there's
+// no fps limit
+const nominalFPS = 25
+
+// framePeriod returns how long a frame without a delay of its own is shown for at the fps limit
+func framePeriod(fps int) time.Duration {
+	if fps == 0 {
+		fps = nominalFPS
+	}
+	return time.Second / time.Duration(fps)
+}
+
 // PlayFromBuff consumes the image.Image files sent into imageBuffer by BufferImages()
 // This function prints the buffer sequentially.
 // Essentially, this is lo-fi in-terminal video playback via UTF-8 / ASCII encoded pixels.
 // For now, use ffmpeg cli to generate frames from a video file.
 func PlayFromBuff(imageBuffer <-chan image.Image, glyphs string, fps int) (err error) {
+	imageBuffer = photerm.AppendTimingStep(imageBuffer, framePeriod(fps))
+	// frames that carry their own delay, like a GIF's, are shown for it
+	imageBuffer = photerm.AppendPacingStep(imageBuffer)
 	if fps != 0 {
 		fpsLimiter := GetFpsLimiter(fps)
 		fpsLimitedBuffer := make(chan image.Image, len(imageBuffer))
 		go fpsLimiter(imageBuffer, fpsLimitedBuffer)
-		return FOutFromBuf(os.Stdout, fpsLimitedBuffer, glyphs, frameEndHooks.Animate)
-	} else {
-		return FOutFromBuf(os.Stdout, imageBuffer, glyphs, frameEndHooks.Animate)
+		imageBuffer = fpsLimitedBuffer
+	}
+	backend := BackendFor(Args.Renderer)
+	newEncoder := func() FrameEncoder { return AnimationEncoder(backend) }
+	hook := backend.Hooks.Animate
+	if !term.IsTerminal(os.Stdout) {
+		return FOutFromBuf(os.Stdout, AppendFrameSteps(imageBuffer), glyphs, newEncoder(), hook)
+	}
+
+	// frames still to be scaled follow the terminal when it's resized
+	var onResize func()
+	if fit, ok := Scales.(*LiveFit); ok {
+		onResize = fit.Refit
+	}
+	imageBuffer = AppendFrameSteps(imageBuffer)
+
+	session, err := StartSession(os.Stdout, onResize, backend.Cleanup)
+	if err != nil {
+		return err
 	}
+	// deferred, so the terminal is restored if anything panics during playback
+	defer session.Close()
+	if err = FOutFromBuf(session, imageBuffer, glyphs, session.Frame(newEncoder), session.FrameEnd(hook)); err != nil {
+		return err
+	}
+	return session.Close()
 }
 
 // PrintFromBuf is designed to print an image or sequence of images to file or stdout.
 // so it uses the print frame end hook
 func PrintFromBuf(imageBuffer <-chan image.Image, glyphs string) (err error) {
-	return FOutFromBuf(os.Stdout, imageBuffer, glyphs, frameEndHooks.Print)
+	backend := BackendFor(Args.Renderer)
+	imageBuffer = AppendFrameSteps(imageBuffer)
+	return FOutFromBuf(os.Stdout, imageBuffer, glyphs, backend.Encode, backend.Hooks.Print)
 }
 
 // FOutFromBuf consumes the image.Image files sent into imageBuffer by BufferImages()
 // This function prints the buffer to the passed io.WriteCloser sequentially.
 // Essentially, this is lo-fi in-terminal video playback via UTF-8 / ASCII encoded pixels.
 // For now, use ffmpeg cli to generate frames from a video file.
-func FOutFromBuf(writer io.WriteCloser, imageBuffer <-chan image.Image, glyphs string, frameEndHook FrameEndHook) (err error) {
+func FOutFromBuf(writer io.WriteCloser, imageBuffer <-chan image.Image, glyphs string, encode FrameEncoder, frameEndHook FrameEndHook) (err error) {
 	palette := MakeCharPalette(glyphs)
+	if Args.Theme == photerm.LightTheme {
+		palette = palette.Inverted()
+	}
 
 	// Use a buffered writer bc it's probably faster
 	bufWriter := bufio.NewWriter(writer)
+	zoom := 1.0
 	for img := range imageBuffer {
+		f := photerm.FrameOf(img)
+		img = f.Image
+		// a frame scaled to follow the resized terminal takes the focus with it
+		if f.Zoom != 0 && f.Zoom != zoom {
+			Args.ScaleFocus(f.Zoom / zoom)
+			zoom = f.Zoom
+		}
 		r := Args.GetFocusView(img).GetRegion()
 
 		// render and print the frame
-		frame := RenderFrame(img, palette, r)
-		printedHeight := len(frame)
-		_, err := fmt.Fprint(bufWriter, strings.Join(frame, "\n"))
+		printedHeight, err := encode(bufWriter, img, palette, r)
 		if err != nil {
 			return err
 		}
@@ -181,38 +335,122 @@ func FOutFromBuf(writer io.WriteCloser, imageBuffer <-chan image.Image, glyphs s
 	return nil
 }
 
-// RenderFrame returns the printable representation of a single frame as a string. Each frame is a slice of strings
-// each string representing a horizontal line of pixels
-func RenderFrame(img image.Image, palette CharPalette, r photerm.Region) (frameLines []string) {
-	frameLines = []string{}
+// RenderFrame returns the cells of a single frame, one pixel per cell, with the glyph
+// chosen by brightness from the palette.
+func RenderFrame(img image.Image, palette CharPalette, r photerm.Region) (grid Grid) {
+	grid = make(Grid, 0, r.Btm-r.Top)
+	method := dither.Methods[Args.Dither]
+	glyphAt := MakeGlyphPicker(img, palette, r, method)
+	colourAt := MakeColourPicker(img, r, method)
 
 	// go row by row in the Scaled image.Image and...
 
 	for y := r.Top; y < r.Btm; y++ {
-		line := []byte{}
-		// print cells from left to right
+		row := make([]Cell, 0, r.Right-r.Left)
+		// fill cells from left to right
 		for x := r.Left; x < r.Right; x++ {
 			rgb := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
-
-			// get brightness of cell
-			c := color.GrayModel.Convert(rgb).(color.Gray).Y
-
-			// rotate hue
-			rotato.RotateHue(&rgb, Args.HueAngle)
+			if skipped(rgb) {
+				row = append(row, Cell{Transparent: true})
+				continue
+			}
 
 			// get the colour and glyph corresponding to the brightness
-			ink := RGB(rgb.R, rgb.G, rgb.B, Foreground)
-
-			line = append(append(line, ink...), []byte(string(palette[c]))...)
+			row = append(row, Cell{Glyph: glyphAt(x, y, rgb), FG: colourAt(x, y, rgb), HasFG: true})
 		}
-		frameLines = append(frameLines, string(line))
+		grid = append(grid, row)
+	}
+	return grid
+}
+
+// DefaultDither settles the dithering when it was left unset. Ordered dithering doesn't shimmer
+// from one frame to the next, so it's used for animation, and stills come out as they always did.
+func DefaultDither(animated bool) {
+	if Args.Dither != "" {
+		return
+	}
+	Args.Dither = "none"
+	if animated {
+		Args.Dither = "bayer4"
 	}
-	return frameLines
 }
 
 func main() {
-	arg.MustParse(&Args)
-	photerm.ArgsToJson(Args)
+	p := arg.MustParse(&Args)
+	backdrop, err := colour.ParseHex(Args.Backdrop)
+	if err != nil {
+		p.Fail(err.Error())
+	}
+	Backdrop = backdrop
+	if Args.PaintBG && Args.Theme == photerm.AutoTheme {
+		// the glyphs are seen against the painted background rather than the terminal's
+		Args.Theme = themeOf(Backdrop)
+	}
+	if Args.Renderer == photerm.Kitty && !KittySupported() {
+		log.Print("the terminal did not answer the kitty graphics query, falling back to half blocks")
+		Args.Renderer = photerm.HalfBlock
+	}
+	if Args.Renderer == photerm.AutoRenderer || Args.Colours == photerm.AutoColours || Args.Theme == photerm.AutoTheme {
+		ApplyCaps(DetectCaps())
+	}
+	if _, ok := Backends[Args.Renderer]; !ok {
+		p.Fail(fmt.Sprintf("unknown renderer: %s", Args.Renderer))
+	}
+	switch Args.Colours {
+	case photerm.TrueColour, photerm.Colours256, photerm.Colours16:
+	default:
+		p.Fail(fmt.Sprintf("unknown colour mode: %s", Args.Colours))
+	}
+	if _, ok := tone.Models[Args.Luma]; !ok {
+		p.Fail(fmt.Sprintf("unknown luminance model: %s", Args.Luma))
+	}
+	switch tone.Target(Args.Tone) {
+	case tone.Glyphs, tone.Colours, tone.Both:
+	default:
+		p.Fail(fmt.Sprintf("unknown tone mapping target: %s", Args.Tone))
+	}
+	switch Args.Alpha {
+	case photerm.Over, photerm.Checker, photerm.Skip:
+	default:
+		p.Fail(fmt.Sprintf("unknown alpha mode: %s", Args.Alpha))
+	}
+	if Args.Threshold != NoThreshold && (Args.Threshold < 0 || Args.Threshold > 255) {
+		p.Fail("threshold must be from 0 to 255")
+	}
+	if Args.AlphaCut < 0 || Args.AlphaCut > 255 {
+		p.Fail("alpha cut must be from 0 to 255")
+	}
+	switch Args.Theme {
+	case photerm.DarkTheme, photerm.LightTheme:
+	default:
+		p.Fail(fmt.Sprintf("unknown theme: %s", Args.Theme))
+	}
+	if _, ok := lut.Interpolations[Args.LutInterp]; !ok {
+		p.Fail(fmt.Sprintf("unknown LUT interpolation: %s", Args.LutInterp))
+	}
+	if Args.LUT != "" {
+		grade, err := lut.Load(Args.LUT)
+		if err != nil {
+			log.Fatalf("LUT ERR: %s", err)
+		}
+		Grade = grade
+	}
+	FitToTerminal()
+	Shading = ShadingOf(Args)
+	if Args.StdInput && Args.Mode == "L" {
+		p.Fail("mode L writes frames next to the video, so it can't read the video from stdin, try mode S")
+	}
+	if _, ok := dither.Methods[Args.Dither]; !ok && Args.Dither != "" {
+		p.Fail(fmt.Sprintf("unknown dithering method: %s", Args.Dither))
+	}
+	// mode I only knows whether it's animating once the image is decoded
+	if Args.Mode != "I" {
+		DefaultDither(true)
+	}
+	// the args are saved next to the input, which stdin doesn't have
+	if !Args.StdInput {
+		photerm.ArgsToJson(Args)
+	}
 
 	charset := strings.Join(Charsets[Args.Charset], "")
 	if Args.Custom != "" {
@@ -229,12 +467,19 @@ func main() {
 
 	case "R":
 		// Provide a DIRECTORY to Mode B for sequential play of all images inside.
+		// Or pipe a stream of images, like concatenated PNGs or MJPEG, into stdin.
+		if Args.StdInput {
+			imageBuffer := make(chan image.Image, 1)
+			util.Must(fc.BufferImageStream(imageBuffer, os.Stdin, Scales))
+			util.Must(PlayFromBuff(imageBuffer, charset, Args.FrameRate))
+			break
+		}
 
 		fc.LoadImageFiles(Args)
 
 		// load image files in a goroutine
 		// ensures playback is not blocked by io.
-		imageBuffer := fc.BufferImageDir(Args)
+		imageBuffer := fc.BufferImageDir(Args, Scales)
 
 		// Consumes image.Image from imageBuffer
 		// Prints each to the terminal.
@@ -242,12 +487,21 @@ func main() {
 
 	case "I":
 		// Provide a full path to Mode A for individual image display.
+		// Or pipe the image into stdin.
 		imageBuffer := make(chan image.Image, 1)
-		fc.BufferImagePath(imageBuffer, Args, Args)
-		PrintFromBuf(imageBuffer, charset)
+		if Args.StdInput {
+			util.Must(fc.BufferImageStream(imageBuffer, os.Stdin, Scales))
+		} else {
+			util.Must(fc.BufferImagePath(imageBuffer, Args, Scales))
+		}
 
-		util.Must(fc.BufferImagePath(imageBuffer, Args, Args))
-		util.Must(PrintFromBuf(imageBuffer, charset))
+		// an animation, or a stream of images, is played rather than printed
+		DefaultDither(fc.Animated())
+		if fc.Animated() {
+			util.Must(PlayFromBuff(imageBuffer, charset, Args.FrameRate))
+		} else {
+			util.Must(PrintFromBuf(imageBuffer, charset))
+		}
 
 	case "S":
 		// S is the streaming mode!
@@ -260,7 +514,7 @@ func main() {
 		buf := make(chan image.Image)
 
 		// here the stream is transformed into a <-chan image.Image
-		go photerm.Stream2Buf(buf, stream, Args)
+		go photerm.Stream2Buf(buf, stream, Scales)
 
 		// and the played out to the terminal
 		util.Must(PlayFromBuff(buf, charset, Args.FrameRate))
@@ -274,7 +528,7 @@ func main() {
 		if err != nil {
 			log.Fatal(err)
 		}
-		buf = photerm.AppendScalingStep(buf, Args)
+		buf = photerm.AppendScalingStep(buf, Scales)
 		util.Must(PlayFromBuff(buf, charset, Args.FrameRate))
 	}
 }
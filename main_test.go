@@ -1,10 +1,10 @@
 package main
 
 import (
+	"fmt"
 	"image"
 	"image/color"
 	"math/rand"
-	"os"
 	"testing"
 	"time"
 	"wombatlord/photerm/photerm_src"
@@ -39,6 +39,36 @@ func (s stubImg) ColorModel() color.Model {
 	return color.RGBAModel
 }
 
+// gradientImg is a stub frame with smooth horizontal bands, closer to a real
+// image than noise when it comes to neighbouring cells sharing a colour
+type gradientImg struct {
+	r photerm.Region
+}
+
+func (g gradientImg) Bounds() image.Rectangle {
+	return image.Rect(g.r.Left, g.r.Top, g.r.Right, g.r.Btm)
+}
+
+func (g gradientImg) At(x, y int) color.Color {
+	return color.RGBA{R: uint8(x / 8), G: uint8(y / 4), B: 128, A: 255}
+}
+
+func (g gradientImg) ColorModel() color.Model {
+	return color.RGBAModel
+}
+
+// byteCounter is an io.WriteCloser that counts what's written to it and throws it away
+type byteCounter struct {
+	n int
+}
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	c.n += len(p)
+	return len(p), nil
+}
+
+func (c *byteCounter) Close() error { return nil }
+
 func BenchmarkRenderFrame(b *testing.B) {
 	charset := MakeCharPalette("#")
 	var img image.Image
@@ -65,9 +95,82 @@ func BenchmarkFoutFromBuf(b *testing.B) {
 		close(res)
 	}(b.N, imageBuf)
 
-	out, err := os.OpenFile(os.DevNull, os.O_APPEND|os.O_RDWR, 0o744)
-	if err != nil {
+	out := &byteCounter{}
+	if err := FOutFromBuf(out, imageBuf, "#", BackendFor(photerm.Glyph).Encode, frameEndHooks.Animate); err != nil {
 		b.Fatalf("%s", err)
 	}
-	FOutFromBuf(out, imageBuf, "#", frameEndHooks.Animate)
+	b.ReportMetric(float64(out.n)/float64(b.N), "bytes/frame")
+}
+
+// BenchmarkEncodeGrid reports the size of an encoded frame and how much of it
+// was saved by only sending colours when they change.
+func BenchmarkEncodeGrid(b *testing.B) {
+	r := photerm.Region{Right: 160, Btm: 90}
+	grid := RenderFrame(gradientImg{r}, MakeCharPalette("#"), r)
+
+	size := func(lines []string) (n int) {
+		for _, line := range lines {
+			n += len(line) + 1
+		}
+		return n
+	}
+	full := size(EncodeGrid(grid, &Pen{verbose: true}))
+
+	for _, tolerance := range []int{0, 4} {
+		b.Run(fmt.Sprintf("tolerance=%d", tolerance), func(b *testing.B) {
+			compressed := 0
+			for n := 0; n < b.N; n++ {
+				compressed = size(EncodeGrid(grid, &Pen{Tolerance: tolerance}))
+			}
+			b.ReportMetric(float64(compressed), "bytes/frame")
+			b.ReportMetric(100*float64(full-compressed)/float64(full), "%saved")
+		})
+	}
+}
+
+// spriteImg is a gradientImg with a white square drawn over it at X,
+// for frames of an animation where little changes between them
+type spriteImg struct {
+	gradientImg
+	X int
+}
+
+func (s spriteImg) At(x, y int) color.Color {
+	if x >= s.X && x < s.X+16 && y >= 16 && y < 32 {
+		return color.White
+	}
+	return s.gradientImg.At(x, y)
+}
+
+// BenchmarkEncodeDiff reports the size of animation frames where a sprite moves across
+// a still background, when only the changed cells are sent.
+func BenchmarkEncodeDiff(b *testing.B) {
+	r := photerm.Region{Right: 160, Btm: 90}
+	palette := MakeCharPalette("#")
+	frames := make([]Grid, 8)
+	for i := range frames {
+		frames[i] = RenderFrame(spriteImg{gradientImg{r}, i * 4}, palette, r)
+	}
+
+	full := 0
+	for _, line := range EncodeGrid(frames[1], &Pen{}) {
+		full += len(line) + 1
+	}
+
+	pen := &Pen{}
+	prev := frames[0]
+	sent := 0
+	for n := 0; n < b.N; n++ {
+		next := frames[1+n%(len(frames)-1)]
+		// copied, as EncodeDiff writes what it sends into prev
+		prev = append(Grid{}, prev...)
+		for y := range prev {
+			prev[y] = append([]Cell{}, prev[y]...)
+		}
+		out, _ := EncodeDiff(prev, next, pen)
+		sent += len(out)
+		prev = next
+	}
+	b.ReportMetric(float64(sent)/float64(b.N), "bytes/frame")
+	b.ReportMetric(100*(1-float64(sent)/float64(b.N)/float64(full)), "%saved")
 }
@@ -0,0 +1,20 @@
+package photerm
+
+import (
+	"io"
+
+	"wombatlord/photerm/src/apng"
+)
+
+// DecodeAPNG decodes every frame of an animated PNG, or the one frame of a PNG that isn't.
+func DecodeAPNG(r io.Reader) ([]*Frame, error) {
+	a, err := apng.DecodeAll(r)
+	if err != nil {
+		return nil, err
+	}
+	frames := make([]*Frame, len(a.Frames))
+	for i, f := range a.Frames {
+		frames[i] = &Frame{Image: f.Image, Delay: f.Delay}
+	}
+	return frames, nil
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"image"
+	"math"
 	"os"
 	"strings"
 	"wombatlord/photerm/src/util"
@@ -13,20 +14,133 @@ const NotSet = 0
 
 type Charset int
 
+// Renderer names the strategy used to turn pixels into terminal cells.
+type Renderer string
+
+const (
+	// AutoRenderer picks the best renderer the terminal supports.
+	AutoRenderer Renderer = "auto"
+	// Glyph paints one pixel per cell, choosing the glyph by brightness.
+	Glyph Renderer = "glyph"
+	// HalfBlock paints two vertically stacked pixels per cell using the
+	// upper half block with separate foreground and background colours.
+	HalfBlock Renderer = "half"
+	// Braille packs a 2x4 block of pixels into each cell as braille dots.
+	Braille Renderer = "braille"
+	// Symbols matches each 4x8 block of pixels against the shapes of block,
+	// sextant and wedge glyphs, picking the best two colour fit.
+	Symbols Renderer = "symbols"
+	// Sixel draws real pixels with DEC sixel graphics.
+	Sixel Renderer = "sixel"
+	// Kitty draws real pixels with the kitty graphics protocol.
+	Kitty Renderer = "kitty"
+	// ITerm draws real pixels with iTerm2 inline images.
+	ITerm Renderer = "iterm"
+)
+
+// CellWidth and CellHeight are the size of a terminal cell in pixels,
+// as assumed by the renderers that draw real pixels.
+var CellWidth, CellHeight = 8, 16
+
+// Packing returns how many pixels of the scaled image the renderer packs
+// into a single terminal cell horizontally and vertically.
+func (r Renderer) Packing() (x, y int) {
+	switch r {
+	case HalfBlock:
+		return 1, 2
+	case Braille:
+		return 2, 4
+	case Symbols:
+		return 4, 8
+	case Sixel, Kitty, ITerm:
+		return CellWidth, CellHeight
+	default:
+		return 1, 1
+	}
+}
+
+// AlphaMode names how the transparent parts of an image are shown.
+type AlphaMode string
+
+const (
+	// Over composites the image over the background colour.
+	Over AlphaMode = "over"
+	// Checker composites the image over a checkerboard, as image editors show transparency.
+	Checker AlphaMode = "checker"
+	// Skip leaves cells that are entirely transparent undrawn, so the image can be drawn over
+	// whatever the terminal already shows.
+	Skip AlphaMode = "skip"
+)
+
+// Theme names the brightness of the terminal's background.
+type Theme string
+
+const (
+	// AutoTheme asks the terminal for its background colour.
+	AutoTheme Theme = "auto"
+	// DarkTheme has dense glyphs for bright pixels.
+	DarkTheme Theme = "dark"
+	// LightTheme has dense glyphs for dark pixels.
+	LightTheme Theme = "light"
+)
+
+// ColourMode names the colour escape sequences the terminal understands.
+type ColourMode string
+
+const (
+	// AutoColours uses as many colours as the terminal supports.
+	AutoColours ColourMode = "auto"
+	// TrueColour paints with 24 bit colour.
+	TrueColour ColourMode = "true"
+	// Colours256 quantizes to the xterm 256 colour palette.
+	Colours256 ColourMode = "256"
+	// Colours16 quantizes to the basic 16 ANSI colours.
+	Colours16 ColourMode = "16"
+)
+
 type Cli struct {
-	Path      string  `arg:"positional" help:"file path for an image" default:"liljeffrey.jpg"`
-	Scale     float64 `arg:"-s, --scale" help:"overall image scale" default:"1.0"`
-	Squash    float64 `arg:"-w, --wide-boyz" help:"How wide you want it guv? (Widens the image)" default:"1.0"`
-	StdInput  bool    `arg:"-i, --in" help:"read from stdin"`
-	Mode      string  `arg:"-m, --mode" help:"mode selection determines renderer" default:"A"`
-	Charset   Charset `arg:"-c, --Charset" help:"Charset selection determines the character set used by the renderer" default:"0"`
-	Custom    string  `arg:"--custom" help:"provide a custom string to render with" default:"█"`
-	YOrigin   int     `arg:"--y-org" help:"minimum Y, top of focus" default:"0"`
-	Height    int     `arg:"--height" help:"height, vertical size of focus" default:"0"`
-	XOrigin   int     `arg:"--x-org" help:"minimum X, left edge of focus" default:"0"`
-	Width     int     `arg:"--width" help:"width, width of focus" default:"0"`
-	HueAngle  float32 `arg:"--hue" help:"hue rotation angle in radians" default:"0.0"`
-	FrameRate int     `arg:"--fps" help:"Provide an integer number of frames per second as an upper limit to the playback speed"`
+	Path      string     `arg:"positional" help:"file path for an image" default:"liljeffrey.jpg"`
+	Scale     float64    `arg:"-s, --scale" help:"overall image scale, fits the terminal when neither this, --cols nor --rows is set"`
+	Squash    float64    `arg:"-w, --wide-boyz" help:"How wide you want it guv? (Widens the image, corrects for the shape of the terminal's cells if unset)"`
+	Cols      int        `arg:"--cols" help:"fit the image within this many columns, in place of a scale"`
+	Rows      int        `arg:"--rows" help:"fit the image within this many rows, in place of a scale"`
+	StdInput  bool       `arg:"-i, --in" help:"read from stdin"`
+	Mode      string     `arg:"-m, --mode" help:"mode selection determines renderer" default:"A"`
+	Charset   Charset    `arg:"-c, --Charset" help:"Charset selection determines the character set used by the renderer" default:"0"`
+	Renderer  Renderer   `arg:"-r, --renderer" help:"renderer selection: auto, glyph, half, braille, symbols, sixel, kitty, iterm" default:"auto"`
+	Threshold int        `arg:"--threshold" help:"brightness (0-255) above which braille dots are lit, dithered with --dither if unset" default:"-1"`
+	Colours   ColourMode `arg:"--colours" help:"colour output: auto, true, 256, 16" default:"auto"`
+	Tolerance int        `arg:"--tolerance" help:"neighbouring colours this close on every channel are sent once, shrinking the output" default:"0"`
+	Redraw    bool       `arg:"--full-redraw" help:"redraw every cell of every animation frame instead of only the ones that changed"`
+	Dither    string     `arg:"--dither" help:"dithering: none, floyd-steinberg, atkinson, bayer2, bayer4, bayer8 (default bayer4 when animating, none for stills)"`
+	Luma      string     `arg:"--luma" help:"luminance model for tone mapping: 601, 709 (in linear light), lab (L*)" default:"601"`
+	Brighten  float64    `arg:"--brightness" help:"brightness added by tone mapping, -1 to 1" default:"0"`
+	Contrast  float64    `arg:"--contrast" help:"contrast multiplier of tone mapping" default:"1.0"`
+	Gamma     float64    `arg:"--gamma" help:"gamma of tone mapping, above 1 lifts the midtones" default:"1.0"`
+	Levels    bool       `arg:"--auto-levels" help:"stretch the brightness of each frame to fill the range"`
+	Equalize  float64    `arg:"--equalize" help:"CLAHE histogram equalization clip limit, try 2 to 4, 0 is off" default:"0"`
+	Tone      string     `arg:"--tone" help:"what tone mapping applies to: glyph, colour, both" default:"both"`
+	Alpha     AlphaMode  `arg:"--alpha" help:"transparency: over (the --bg colour), checker, skip (leaves transparent cells undrawn)" default:"over"`
+	Backdrop  string     `arg:"--bg" help:"colour to composite transparency over, as hex" default:"#000000"`
+	AlphaCut  int        `arg:"--alpha-cut" help:"alpha (1-255) below which pixels are fully transparent, 128 when skipping if unset" default:"0"`
+	Theme     Theme      `arg:"--theme" help:"terminal background: auto (asks the terminal), dark, light (dense glyphs for dark pixels)" default:"auto"`
+	PaintBG   bool       `arg:"--paint-bg" help:"paint the --bg colour behind every cell, so the output looks the same on any theme"`
+	Custom    string     `arg:"--custom" help:"provide a custom string to render with, overrides the Charset"`
+	YOrigin   int        `arg:"--y-org" help:"minimum Y, top of focus" default:"0"`
+	Height    int        `arg:"--height" help:"height, vertical size of focus" default:"0"`
+	XOrigin   int        `arg:"--x-org" help:"minimum X, left edge of focus" default:"0"`
+	Width     int        `arg:"--width" help:"width, width of focus" default:"0"`
+	HueAngle  float32    `arg:"--hue" help:"hue rotation angle in radians" default:"0.0"`
+	LUT       string     `arg:"--lut" help:"path to a .cube 3D LUT to grade every frame with"`
+	LutInterp string     `arg:"--lut-interp" help:"LUT interpolation: trilinear, tetrahedral" default:"tetrahedral"`
+	HueCycle  float64    `arg:"--hue-cycle" help:"turn the hue continuously, in radians per second" default:"0"`
+	Rainbow   float64    `arg:"--rainbow" help:"tint with this many rainbows across the image, they move with --hue-cycle" default:"0"`
+	Pulse     float64    `arg:"--pulse" help:"pulse the saturation between full colour and grey this many times a second" default:"0"`
+	Saturate  float64    `arg:"--saturation" help:"saturation multiplier, 0 is grey" default:"1.0"`
+	Vibrance  float64    `arg:"--vibrance" help:"saturate muted colours more than colourful ones, negative mutes" default:"0"`
+	Sepia     float64    `arg:"--sepia" help:"sepia toning, 0 to 1" default:"0"`
+	Grayscale float64    `arg:"--grayscale" help:"drain the colour, 0 to 1" default:"0"`
+	FrameRate int        `arg:"--fps" help:"Provide an integer number of frames per second as an upper limit to the playback speed"`
 }
 
 func (c Cli) GetPath() string    { return c.Path }
@@ -46,6 +160,12 @@ func (c Cli) GetRegion() Region {
 	}
 }
 
+// GetPacking reports the pixels per cell of the selected renderer.
+func (c Cli) GetPacking() (x, y int) { return c.Renderer.Packing() }
+
+// GetFit reports the columns and rows the image should fill, NotSet when there's no limit.
+func (c Cli) GetFit() (cols, rows int) { return c.Cols, c.Rows }
+
 func (c *Cli) GetFocusView(img image.Image) FocusView {
 	// set defaults as dynamic image size
 	if c.Height == NotSet {
@@ -64,6 +184,15 @@ func (c *Cli) GetFocusView(img image.Image) FocusView {
 	return c
 }
 
+// ScaleFocus moves and resizes the focus view by k, so a pinned region shows the same part
+// of an image rescaled by k.
+func (c *Cli) ScaleFocus(k float64) {
+	c.XOrigin = int(math.Round(float64(c.XOrigin) * k))
+	c.Width = int(math.Round(float64(c.Width) * k))
+	c.YOrigin = int(math.Round(float64(c.YOrigin) * k))
+	c.Height = int(math.Round(float64(c.Height) * k))
+}
+
 // ArgsToJson serialises the passed CLI args.
 // The output file will take its name from Args.Path
 func ArgsToJson(c Cli) {
@@ -0,0 +1,28 @@
+package photerm
+
+import (
+	"image"
+	"image/draw"
+
+	// the formats frames can be decoded from, image.Decode sniffs which one a file is
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+// normalize returns img in a form every pipeline step reads quickly and alike. The 8 bit
+// formats decoders produce are kept as they are, anything else, like 16 bit or paletted
+// images, is converted to RGBA.
+func normalize(img image.Image) image.Image {
+	switch img.(type) {
+	case *image.RGBA, *image.NRGBA, *image.YCbCr, *image.Gray:
+		return img
+	}
+	rgba := image.NewRGBA(img.Bounds())
+	draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	return rgba
+}
@@ -0,0 +1,94 @@
+package photerm
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"testing"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+)
+
+// testImage is 2x2 with a known colour in the top left
+func testImage() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+	img.SetNRGBA(0, 0, color.NRGBA{R: 200, G: 100, B: 50, A: 255})
+	return img
+}
+
+// webpGrey is a 1x1 lossy WebP of a light grey pixel, there's no WebP encoder to make one with
+const webpGrey = "UklGRiQAAABXRUJQVlA4IBgAAAAwAQCdASoBAAEAAwA0JaQAA3AA/vuUAAA="
+
+func TestDecodeFrames(t *testing.T) {
+	top := color.RGBA{R: 200, G: 100, B: 50, A: 255}
+	deep := image.NewNRGBA64(image.Rect(0, 0, 2, 2))
+	deep.SetNRGBA64(0, 0, color.NRGBA64{R: 200 * 257, G: 100 * 257, B: 50 * 257, A: 0xffff})
+	deep.SetNRGBA64(1, 1, color.NRGBA64{R: 0xffff, A: 0x8080})
+	paletted := image.NewPaletted(image.Rect(0, 0, 2, 2), color.Palette{top, color.RGBA{}})
+	paletted.SetColorIndex(1, 1, 1)
+
+	encode := func(enc func(io.Writer) error) []byte {
+		var buf bytes.Buffer
+		if err := enc(&buf); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	webp, _ := base64.StdEncoding.DecodeString(webpGrey)
+
+	cases := []struct {
+		name, format string
+		data         []byte
+		at           image.Point
+		want         color.RGBA
+	}{
+		{"png", "png", encode(func(w io.Writer) error { return png.Encode(w, testImage()) }), image.Pt(0, 0), top},
+		{"16 bit png", "png", encode(func(w io.Writer) error { return png.Encode(w, deep) }), image.Pt(0, 0), top},
+		{"16 bit png alpha", "png", encode(func(w io.Writer) error { return png.Encode(w, deep) }), image.Pt(1, 1), color.RGBA{R: 128, A: 128}},
+		{"paletted png", "png", encode(func(w io.Writer) error { return png.Encode(w, paletted) }), image.Pt(0, 0), top},
+		{"paletted png transparency", "png", encode(func(w io.Writer) error { return png.Encode(w, paletted) }), image.Pt(1, 1), color.RGBA{}},
+		{"gif", "gif", encode(func(w io.Writer) error { return gif.Encode(w, paletted, nil) }), image.Pt(0, 0), top},
+		{"bmp", "bmp", encode(func(w io.Writer) error { return bmp.Encode(w, testImage()) }), image.Pt(0, 0), top},
+		{"tiff", "tiff", encode(func(w io.Writer) error { return tiff.Encode(w, testImage(), nil) }), image.Pt(0, 0), top},
+		{"16 bit tiff", "tiff", encode(func(w io.Writer) error { return tiff.Encode(w, deep, nil) }), image.Pt(0, 0), top},
+		{"jpeg", "jpeg", encode(func(w io.Writer) error { return jpeg.Encode(w, image.NewGray(image.Rect(0, 0, 2, 2)), nil) }), image.Pt(0, 0), color.RGBA{A: 255}},
+		{"webp", "webp", webp, image.Pt(0, 0), color.RGBA{R: 235, G: 235, B: 235, A: 255}},
+	}
+	for _, c := range cases {
+		frames, format, err := DecodeFrames(bytes.NewReader(c.data))
+		if err != nil {
+			t.Errorf("%s: %s", c.name, err)
+			continue
+		}
+		if format != c.format {
+			t.Errorf("%s: decoded as %s", c.name, format)
+		}
+		if len(frames) != 1 {
+			t.Errorf("%s: got %d frames, want 1", c.name, len(frames))
+			continue
+		}
+		// this is the conversion every renderer makes
+		got := color.RGBAModel.Convert(frames[0].At(c.at.X, c.at.Y)).(color.RGBA)
+		if got != c.want {
+			t.Errorf("%s: got %v at %v, want %v", c.name, got, c.at, c.want)
+		}
+	}
+}
+
+func TestDecodeFramesNotAnImage(t *testing.T) {
+	for _, data := range []string{`{"Path": "frames/"}`, "\x00\x00\x00\x18ftypmp42"} {
+		if _, _, err := DecodeFrames(bytes.NewReader([]byte(data))); !errors.Is(err, image.ErrFormat) {
+			t.Errorf("%q: got %v, want %v", data, err, image.ErrFormat)
+		}
+	}
+}
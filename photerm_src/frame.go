@@ -0,0 +1,99 @@
+package photerm
+
+import (
+	"image"
+	"time"
+)
+
+// Frame is an image on its way down the pipeline, with its place in the animation.
+// Pipeline steps that make a new image from a frame pass the place on with Frame.With.
+type Frame struct {
+	image.Image
+	// Delay is how long the frame stays on screen, 0 when the source doesn't say
+	Delay time.Duration
+	// Time is when the frame is shown, counted from the first frame
+	Time time.Duration
+	// Zoom is how much larger the frame was scaled than it would have been at the size of the
+	// terminal when playback started, 0 when it wasn't scaled to follow the terminal
+	Zoom float64
+}
+
+// FrameOf returns img as a Frame. An image that isn't one already is the first frame, with no delay.
+func FrameOf(img image.Image) *Frame {
+	if f, ok := img.(*Frame); ok {
+		return f
+	}
+	return &Frame{Image: img}
+}
+
+// With returns a frame of img in the same place in the animation as f.
+func (f *Frame) With(img image.Image) *Frame {
+	return &Frame{Image: img, Delay: f.Delay, Time: f.Time, Zoom: f.Zoom}
+}
+
+// TimeTransform is a pipeline step timing the frames in the order they come, each is shown once
+// the frames before it have been for their delays. Frames without a delay are taken to last period.
+func TimeTransform(out chan<- image.Image, in <-chan image.Image, period time.Duration) {
+	defer Guard()
+	defer close(out)
+	var at time.Duration
+	for img := range in {
+		src := FrameOf(img)
+		f := src.With(src.Image)
+		f.Time = at
+		out <- f
+		if f.Delay != 0 {
+			at += f.Delay
+		} else {
+			at += period
+		}
+	}
+}
+
+// AppendTimingStep attaches the timing pipeline step to the image buffer
+func AppendTimingStep(in <-chan image.Image, period time.Duration) <-chan image.Image {
+	out := make(chan image.Image, cap(in))
+	go TimeTransform(out, in, period)
+
+	return out
+}
+
+// PaceTransform is a pipeline step holding each frame back until the one before it has
+// been shown for its delay. Frames without a delay hold nothing back.
+func PaceTransform(out chan<- image.Image, in <-chan image.Image) {
+	defer Guard()
+	defer close(out)
+	var due time.Time
+	for img := range in {
+		time.Sleep(time.Until(due))
+		out <- img
+		due = time.Now().Add(FrameOf(img).Delay)
+	}
+}
+
+// AppendPacingStep attaches the pacing pipeline step to the image buffer
+func AppendPacingStep(in <-chan image.Image) <-chan image.Image {
+	out := make(chan image.Image)
+	go PaceTransform(out, in)
+
+	return out
+}
+
+// MapTransform is a pipeline step making a new image of every frame with transform,
+// keeping each in its place in the animation.
+func MapTransform(out chan<- image.Image, in <-chan image.Image, transform func(f *Frame) image.Image) {
+	defer Guard()
+	defer close(out)
+	for img := range in {
+		f := FrameOf(img)
+		out <- f.With(transform(f))
+	}
+}
+
+// AppendMappingStep attaches a mapping pipeline step to the image buffer
+func AppendMappingStep(in <-chan image.Image, transform func(f *Frame) image.Image) <-chan image.Image {
+	out := make(chan image.Image)
+	go MapTransform(out, in, transform)
+
+	return out
+}
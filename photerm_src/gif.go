@@ -0,0 +1,67 @@
+package photerm
+
+import (
+	"errors"
+	"image"
+	"image/draw"
+	"image/gif"
+	"io"
+	"time"
+)
+
+// minGIFDelay is the shortest delay a GIF frame is shown for, browsers slow anything quicker
+// down to defaultGIFDelay and plenty of GIFs in the wild depend on it.
+const (
+	minGIFDelay     = 20 * time.Millisecond
+	defaultGIFDelay = 100 * time.Millisecond
+)
+
+// DecodeGIF decodes every frame of an animated GIF. GIF frames only carry the part of the
+// picture that changed, so each is drawn over what the disposal of the frame before left
+// behind, giving whole frames with their delays.
+func DecodeGIF(r io.Reader) ([]*Frame, error) {
+	g, err := gif.DecodeAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(g.Image) == 0 {
+		return nil, errors.New("gif: no frames")
+	}
+
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	canvas := image.NewRGBA(bounds)
+	frames := make([]*Frame, 0, len(g.Image))
+	for i, img := range g.Image {
+		var previous *image.RGBA
+		if g.Disposal[i] == gif.DisposalPrevious {
+			previous = cloneRGBA(canvas)
+		}
+
+		draw.Draw(canvas, img.Bounds(), img, img.Bounds().Min, draw.Over)
+		frames = append(frames, &Frame{Image: cloneRGBA(canvas), Delay: gifDelay(g.Delay[i])})
+
+		// disposal decides what the next frame is drawn over
+		switch g.Disposal[i] {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, img.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+	return frames, nil
+}
+
+// gifDelay converts a delay in hundredths of a second, as GIFs store it
+func gifDelay(centiseconds int) time.Duration {
+	delay := time.Duration(centiseconds) * 10 * time.Millisecond
+	if delay < minGIFDelay {
+		return defaultGIFDelay
+	}
+	return delay
+}
+
+func cloneRGBA(img *image.RGBA) *image.RGBA {
+	clone := image.NewRGBA(img.Bounds())
+	copy(clone.Pix, img.Pix)
+	return clone
+}
@@ -0,0 +1,91 @@
+package photerm
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+	"time"
+)
+
+func TestDecodeGIF(t *testing.T) {
+	clear, red, green, blue := color.RGBA{}, color.RGBA{R: 255, A: 255}, color.RGBA{G: 255, A: 255}, color.RGBA{B: 255, A: 255}
+	palette := color.Palette{clear, red, green, blue}
+	// frame returns a paletted frame from x0 along the one row of the canvas
+	frame := func(x0 int, indices ...uint8) *image.Paletted {
+		img := image.NewPaletted(image.Rect(x0, 0, x0+len(indices), 1), palette)
+		copy(img.Pix, indices)
+		return img
+	}
+
+	g := &gif.GIF{
+		Image: []*image.Paletted{
+			frame(0, 1, 1, 1),
+			frame(1, 2),
+			frame(2, 3),
+			// clear pixels leave what's under them
+			frame(0, 2, 0, 0),
+		},
+		Delay:    []int{0, 1, 2, 5},
+		Disposal: []byte{gif.DisposalNone, gif.DisposalBackground, gif.DisposalPrevious, gif.DisposalNone},
+		Config:   image.Config{ColorModel: palette, Width: 3, Height: 1},
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name  string
+		want  []color.RGBA
+		delay time.Duration
+	}{
+		// no delay, and delays under 20ms, are slowed down to 100ms as browsers do
+		{"first", []color.RGBA{red, red, red}, 100 * time.Millisecond},
+		{"over the last", []color.RGBA{red, green, red}, 100 * time.Millisecond},
+		// the green was cleared back to the background by the last frame's disposal
+		{"over the cleared", []color.RGBA{red, clear, blue}, 20 * time.Millisecond},
+		// and the blue was put back to how it was before the last frame
+		{"over the previous", []color.RGBA{green, clear, red}, 50 * time.Millisecond},
+	}
+	frames, err := DecodeGIF(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != len(cases) {
+		t.Fatalf("got %d frames, want %d", len(frames), len(cases))
+	}
+	for i, c := range cases {
+		f := frames[i]
+		if f.Image.Bounds() != image.Rect(0, 0, 3, 1) {
+			t.Errorf("%s: frame is %v, want the whole canvas", c.name, f.Image.Bounds())
+		}
+		for x, want := range c.want {
+			if got := color.RGBAModel.Convert(f.Image.At(x, 0)).(color.RGBA); got != want {
+				t.Errorf("%s: pixel %d is %v, want %v", c.name, x, got, want)
+			}
+		}
+		if f.Delay != c.delay {
+			t.Errorf("%s: delay %v, want %v", c.name, f.Delay, c.delay)
+		}
+	}
+}
+
+func TestGIFDelay(t *testing.T) {
+	cases := []struct {
+		centiseconds int
+		want         time.Duration
+	}{
+		{0, 100 * time.Millisecond},
+		{1, 100 * time.Millisecond},
+		{2, 20 * time.Millisecond},
+		{4, 40 * time.Millisecond},
+		{100, time.Second},
+	}
+	for _, c := range cases {
+		if got := gifDelay(c.centiseconds); got != c.want {
+			t.Errorf("%d: got %v, want %v", c.centiseconds, got, c.want)
+		}
+	}
+}
@@ -1,13 +1,20 @@
 package photerm
 
 import (
+	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
 	"image"
+	"io"
 	"log"
+	"math"
 	"os"
-	"strings"
+	"sync/atomic"
 
 	"github.com/nfnt/resize"
+	"wombatlord/photerm/src/apng"
+	"wombatlord/photerm/src/util"
 )
 
 // These are interfaces to the CLI struct
@@ -23,6 +30,26 @@ type FocusView interface {
 type ScaleFactors interface {
 	GetScale() float64
 	GetSquash() float64
+	Fitter
+}
+
+// Fitter is a box of terminal cells for frames to fill,
+// and the pixels per cell they're drawn with.
+type Fitter interface {
+	GetPacking() (x, y int)
+	GetFit() (cols, rows int)
+}
+
+// Limiter is ScaleFactors with a box of terminal cells that frames are shrunk to fit
+// within, whatever their scale. A side left NotSet doesn't limit them.
+type Limiter interface {
+	GetLimit() (cols, rows int)
+}
+
+// Zoomer is ScaleFactors that follow the size of the terminal. Zoom reports how much larger
+// img is scaled now than it would have been when playback started.
+type Zoomer interface {
+	Zoom(img image.Image) float64
 }
 
 type PathSpec interface {
@@ -34,15 +61,39 @@ type PathSpec interface {
 // Essentially crops the image post scaling if values are non-default.
 type Region struct{ Left, Top, Right, Btm int }
 
-// OutputBoundsOf consumes a Cli value and returns pixel width, height tuple
+// OutputBoundsOf consumes a Cli value and returns pixel width, height tuple.
+// Scale and squash are measured in terminal cells, so the result is multiplied
+// by the renderer's packing to keep the same footprint and aspect ratio.
+// When there are cols or rows to fit the scale is chosen to fill them instead.
 func OutputDimsOf(scales ScaleFactors, img image.Image) (w, h uint) {
 	height := float64(uint(img.Bounds().Max.Y))
 	width := float64(uint(img.Bounds().Max.X))
 
 	scale := scales.GetScale()
 	ratio := width / height * scales.GetSquash()
+	px, py := scales.GetPacking()
+	if cols, rows := scales.GetFit(); cols != NotSet || rows != NotSet {
+		scale = FitScale(height*ratio, height, cols, rows)
+	}
+	if limiter, ok := scales.(Limiter); ok {
+		cols, rows := limiter.GetLimit()
+		scale = math.Min(scale, FitScale(height*ratio, height, cols, rows))
+	}
+
+	return uint(scale * height * ratio * float64(px)), uint(scale * height * float64(py))
+}
 
-	return uint(scale * height * ratio), uint(scale * height)
+// FitScale returns the largest scale at which w by h cells fits within cols by rows.
+// A side left NotSet doesn't limit the scale.
+func FitScale(w, h float64, cols, rows int) float64 {
+	scale := math.Inf(1)
+	if cols != NotSet {
+		scale = float64(cols) / w
+	}
+	if rows != NotSet {
+		scale = math.Min(scale, float64(rows)/h)
+	}
+	return scale
 }
 
 // Just experimenting and exploring abstraction.
@@ -52,8 +103,12 @@ type FrameCache struct {
 	imageFiles  []os.DirEntry
 	imageFile   *os.File
 	frame       image.Image
+	frames      []*Frame
 	imageFormat string
 	frameErr    error
+
+	// streaming is set when more images follow the last one decoded from a stream
+	streaming bool
 }
 
 // ScaleImg does global scale and makes boyz wide
@@ -86,61 +141,131 @@ func (fc *FrameCache) LoadImageFile(file os.DirEntry, path PathSpec) *os.File {
 	return fc.imageFile
 }
 
-// DecodeFrame pulls a pointer to a jpg/png from the imageFiles array.
-// the *os.File is then decoded into an image.Image for processing and render.
-func (fc *FrameCache) DecodeFrame(frame *os.File) {
-	fc.frame, fc.imageFormat, fc.frameErr = image.Decode(frame)
+// DecodeFrames decodes an image of any registered format into its frames. Animated GIFs and
+// PNGs give every frame with its delay, anything else is the one frame. Data that isn't an
+// image of any registered format is an image.ErrFormat.
+func DecodeFrames(r io.Reader) (frames []*Frame, format string, err error) {
+	br := bufio.NewReader(r)
+	switch magic, _ := br.Peek(len(apng.Signature)); {
+	case bytes.HasPrefix(magic, []byte("GIF8")):
+		format = "gif"
+		frames, err = DecodeGIF(br)
+	case string(magic) == apng.Signature:
+		format = "png"
+		frames, err = DecodeAPNG(br)
+	default:
+		var img image.Image
+		img, format, err = image.Decode(br)
+		frames = []*Frame{{Image: img}}
+	}
+	if err != nil {
+		return nil, format, err
+	}
+	for _, f := range frames {
+		f.Image = normalize(f.Image)
+	}
+	return frames, format, nil
+}
+
+// DecodeFrame pulls a pointer to an image from the imageFiles array.
+// the *os.File is then decoded into image.Images for processing and render.
+// A file that isn't an image of any registered format is reported with false.
+func (fc *FrameCache) DecodeFrame(frame *os.File) bool {
+	defer frame.Close()
+	fc.frames, fc.imageFormat, fc.frameErr = DecodeFrames(frame)
+	if errors.Is(fc.frameErr, image.ErrFormat) {
+		return false
+	}
 	if fc.frameErr != nil {
 		log.Fatalf("DECODE ERR: %s", fc.frameErr)
 	}
-	_ = frame.Close()
+	fc.frame = fc.frames[0].Image
+	return true
 }
 
 func (fc *FrameCache) GetFrame() image.Image {
 	return fc.frame
 }
 
-// Returns the extension of a file, eg. .json, .jpg, .mp4
-func (fc *FrameCache) checkExtension(f os.DirEntry) string {
-	return strings.Split(f.Name(), ".")[1]
+// Animated reports whether the last image decoded has more than one frame,
+// or is the first of a stream of them.
+func (fc *FrameCache) Animated() bool {
+	return len(fc.frames) > 1 || fc.streaming
+}
+
+// sendScaled scales every frame of the last image decoded into results
+func (fc *FrameCache) sendScaled(results chan<- image.Image, sf ScaleFactors) {
+	for _, f := range fc.frames {
+		results <- ScaleFrame(f, sf)
+	}
+}
+
+// panicHook holds the func(v interface{}) Guard calls
+var panicHook atomic.Value
+
+// OnPanic sets what to call with the value of a panic in one of the pipeline's goroutines, before
+// the panic carries on and ends the program. Whatever owns the terminal can put it back first.
+func OnPanic(hook func(v interface{})) {
+	panicHook.Store(hook)
 }
 
+// Guard is deferred by every goroutine the pipeline starts, so that a panic in one is seen by
+// the OnPanic hook. Only the goroutine that panics could recover it, deferring in main won't do.
+func Guard() {
+	if v := recover(); v != nil {
+		if hook, _ := panicHook.Load().(func(v interface{})); hook != nil {
+			hook(v)
+		}
+		panic(v)
+	}
+}
+
+// prefetch is how many frames of a directory are decoded ahead of playback, a second of video
+const prefetch = 24
+
 // BufferImageDir runs asynchronously to load files into memory
 // Each file is sent into imageBuffer to be consumed elsewhere.
 // This is an example of a generator pattern in golang.
-func (fc *FrameCache) BufferImageDir(args Cli) <-chan image.Image {
+func (fc *FrameCache) BufferImageDir(args Cli, sf ScaleFactors) <-chan image.Image {
 	// Define the asynchronous work that will process data and populate the generator
 	// Anonymous func takes the write side of a channel
 	work := func(results chan<- image.Image) {
+		defer Guard()
 		for _, file := range fc.imageFiles {
 
-			// Ignore serialised args file / source mp4 and proceed with iteration
-			if fc.checkExtension(file) != "jpg" {
+			if file.IsDir() {
 				continue
 			}
 
-			// Load & Decode the .jpg file into an image.Image
+			// Load & Decode the file into image.Images, an animation gives all of its frames.
+			// Ignore serialised args file / source mp4 and proceed with iteration
 			imgFile := fc.LoadImageFile(file, args)
-			fc.DecodeFrame(imgFile)
+			if !fc.DecodeFrame(imgFile) {
+				continue
+			}
 
-			// Scale image, then read image.Image into channel.
-			results <- fc.ScaleImg(args)
+			// Read the frames into the channel, they're scaled on the way out
+			for _, f := range fc.frames {
+				results <- f
+			}
 		}
 		// Close the channel once all files have been read into it.
 		close(results)
 	}
 
 	// blocking code
-	imageBuffer := make(chan image.Image, len(fc.imageFiles))
+	imageBuffer := make(chan image.Image, util.Min(len(fc.imageFiles), prefetch))
 	// non-blocking
 	go work(imageBuffer)
 
-	// Return the read side of the channel
-	return imageBuffer
+	// Return the read side of the channel, scaling each frame as it's taken rather than as it's
+	// buffered, so the frames follow any change to the scale factors straight away
+	return AppendScalingStep(imageBuffer, sf)
 }
 
 // Buffer a single image for non-sequential display
-// from a full provided path.
+// from a full provided path. The image is decoded up front, so Animated
+// can tell whether it's an animation, and its frames are sent asynchronously.
 func (fc *FrameCache) BufferImagePath(imageBuffer chan image.Image, path PathSpec, sf ScaleFactors) (err error) {
 
 	imgFile, err := os.Open(path.GetPath())
@@ -149,11 +274,67 @@ func (fc *FrameCache) BufferImagePath(imageBuffer chan image.Image, path PathSpe
 		log.Fatalf("LOAD ERR: %s", err)
 	}
 
-	fc.DecodeFrame(imgFile)
+	if !fc.DecodeFrame(imgFile) {
+		log.Fatalf("DECODE ERR: %s", fc.frameErr)
+	}
 
-	// Scale image, then read image.Image into channel.
-	imageBuffer <- fc.ScaleImg(sf)
-	close(imageBuffer)
+	// Scale the frames, then read them into the channel.
+	go func() {
+		defer Guard()
+		fc.sendScaled(imageBuffer, sf)
+		close(imageBuffer)
+	}()
+	return nil
+}
+
+// BufferImageStream buffers the images read from r, which may be one image or a stream of them
+// concatenated, as piped into stdin. The first image is decoded up front and the stream is held
+// until the next image arrives or the stream ends, so Animated can tell a still from a stream.
+func (fc *FrameCache) BufferImageStream(imageBuffer chan image.Image, r io.Reader, sf ScaleFactors) (err error) {
+	images := make(chan []byte)
+	cutErr := make(chan error, 1)
+	go func() {
+		defer Guard()
+		cutErr <- CutImagesFromStream(images, r)
+	}()
+
+	first, ok := <-images
+	if !ok {
+		if err = <-cutErr; err == nil {
+			err = errors.New("no image in the stream")
+		}
+		return err
+	}
+	fc.frames, fc.imageFormat, fc.frameErr = DecodeFrames(bytes.NewReader(first))
+	if fc.frameErr != nil {
+		return fc.frameErr
+	}
+	fc.frame = fc.frames[0].Image
+	next, more := <-images
+	fc.streaming = more
+
+	// the rest of the stream, with the image that was waited for put back in front
+	rest := make(chan []byte)
+	go func() {
+		defer Guard()
+		defer close(rest)
+		if more {
+			rest <- next
+			for img := range images {
+				rest <- img
+			}
+		}
+		if err := <-cutErr; err != nil {
+			log.Fatalf("STREAM ERR: %s", err)
+		}
+	}()
+
+	// Scale the frames, then read them into the channel.
+	go func() {
+		defer Guard()
+		fc.sendScaled(imageBuffer, sf)
+		Stream2Buf(imageBuffer, rest, sf)
+	}()
 	return nil
 }
 
@@ -166,15 +347,28 @@ func ScaleImgNoFC(img image.Image, sf ScaleFactors) image.Image {
 
 // ScaleTransform is ScaleImg wrapped as a pipeline step, i.e.
 // an async generator that has an input and an output.
+// Frames keep their place in the animation.
 func ScaleTransform(
 	out chan<- image.Image,
 	in <-chan image.Image,
 	sf ScaleFactors,
 ) {
+	defer Guard()
 	defer close(out)
 	for img := range in {
-		out <- ScaleImgNoFC(img, sf)
+		out <- ScaleFrame(FrameOf(img), sf)
+	}
+}
+
+// ScaleFrame scales a frame, keeping its place in the animation. The scale factors are read
+// afresh for every frame, so when they follow the terminal each frame is scaled once, from
+// the source, to the size of the terminal at the time.
+func ScaleFrame(f *Frame, sf ScaleFactors) *Frame {
+	scaled := f.With(ScaleImgNoFC(f.Image, sf))
+	if zoomer, ok := sf.(Zoomer); ok {
+		scaled.Zoom = zoomer.Zoom(f.Image)
 	}
+	return scaled
 }
 
 // AppendScalingStep attaches the scaling pipeline step to the image buffer
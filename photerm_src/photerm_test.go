@@ -0,0 +1,79 @@
+package photerm
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+// limited is Cli with a Limiter on top, like a fit that has followed the terminal
+type limited struct {
+	Cli
+	cols, rows int
+}
+
+func (l limited) GetLimit() (cols, rows int) { return l.cols, l.rows }
+
+func TestOutputDimsOf(t *testing.T) {
+	// 100 by 50, twice as wide as it's tall
+	img := image.NewRGBA(image.Rect(0, 0, 100, 50))
+	at := func(scale, squash float64, renderer Renderer) Cli {
+		return Cli{Scale: scale, Squash: squash, Renderer: renderer}
+	}
+	fit := func(cols, rows int, renderer Renderer) Cli {
+		return Cli{Scale: 1, Squash: 1, Cols: cols, Rows: rows, Renderer: renderer}
+	}
+
+	cases := []struct {
+		name   string
+		scales ScaleFactors
+		w, h   uint
+	}{
+		{"scale", at(0.5, 1, Glyph), 50, 25},
+		{"squash", at(0.5, 0.5, Glyph), 25, 25},
+		// the scale is in cells, so renderers that pack more pixels in each get more of them
+		{"half blocks", at(0.5, 1, HalfBlock), 50, 50},
+		{"braille", at(0.5, 1, Braille), 100, 100},
+		{"symbols", at(0.5, 1, Symbols), 200, 200},
+		{"cols", fit(40, NotSet, Glyph), 40, 20},
+		{"rows", fit(NotSet, 10, Glyph), 20, 10},
+		{"cols and rows, rows smaller", fit(40, 10, Glyph), 20, 10},
+		{"cols and rows, cols smaller", fit(40, 100, Glyph), 40, 20},
+		{"cols ignore the scale", Cli{Scale: 0.1, Squash: 1, Cols: 40}, 40, 20},
+		{"cols with squash", Cli{Scale: 1, Squash: 0.5, Cols: 40}, 40, 40},
+		{"cols in half blocks", fit(40, NotSet, HalfBlock), 40, 40},
+		{"rows in braille", fit(NotSet, 10, Braille), 40, 40},
+		{"limit caps the scale", limited{at(1, 1, Glyph), 30, NotSet}, 30, 15},
+		{"limit on rows", limited{at(1, 1, Glyph), NotSet, 10}, 20, 10},
+		{"limit doesn't grow", limited{at(0.2, 1, Glyph), 80, 80}, 20, 10},
+		{"no limit", limited{at(1, 1, Glyph), NotSet, NotSet}, 100, 50},
+		{"limit caps the fit", limited{fit(40, NotSet, Glyph), NotSet, 10}, 20, 10},
+		{"limit in half blocks", limited{at(1, 1, HalfBlock), 30, NotSet}, 30, 30},
+	}
+	for _, c := range cases {
+		if w, h := OutputDimsOf(c.scales, img); w != c.w || h != c.h {
+			t.Errorf("%s: got %dx%d, want %dx%d", c.name, w, h, c.w, c.h)
+		}
+	}
+}
+
+func TestFitScale(t *testing.T) {
+	cases := []struct {
+		name       string
+		w, h       float64
+		cols, rows int
+		want       float64
+	}{
+		{"cols", 100, 50, 40, NotSet, 0.4},
+		{"rows", 100, 50, NotSet, 10, 0.2},
+		{"both, rows smaller", 100, 50, 40, 10, 0.2},
+		{"both, cols smaller", 100, 50, 40, 40, 0.4},
+		{"grows to fit", 10, 5, 40, 40, 4},
+		{"neither", 100, 50, NotSet, NotSet, math.Inf(1)},
+	}
+	for _, c := range cases {
+		if got := FitScale(c.w, c.h, c.cols, c.rows); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package photerm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// PNGHead is the signature every PNG starts with
+var PNGHead = []byte{0x89, 0x50, 0x4e, 0x47, 0x0d, 0x0a, 0x1a, 0x0a}
+
+// maxPNGChunk is the longest a PNG chunk is allowed to be
+const maxPNGChunk = 1<<31 - 1
+
+// JPEGHead starts every JPEG, the start of image marker and the start of the next marker
+var JPEGHead = []byte{0xff, 0xd8, 0xff}
+
+// JPEG markers that matter for finding where an image ends
+const (
+	jpegSOI = 0xd8
+	jpegEOI = 0xd9
+	jpegSOS = 0xda
+	jpegTEM = 0x01
+)
+
+// CutImagesFromStream sends each image of a stream of concatenated images down out, closing it
+// when the stream ends. PNG streams are cut with CutPNGsFromStream and MJPEG with
+// CutJPEGsFromStream. Other formats can't be cut, so the whole stream is taken to be one image.
+func CutImagesFromStream(out chan<- []byte, r io.Reader) error {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(len(PNGHead))
+	switch {
+	case bytes.Equal(magic, PNGHead):
+		return CutPNGsFromStream(out, br)
+	case bytes.HasPrefix(magic, JPEGHead):
+		return CutJPEGsFromStream(out, br)
+	}
+
+	defer close(out)
+	data, err := io.ReadAll(br)
+	if len(data) > 0 {
+		out <- data
+	}
+	return err
+}
+
+// CutPNGsFromStream sends each PNG of a stream of concatenated PNGs down out, closing it when
+// the stream ends. The images are cut by reading their chunks up to the IEND chunk, as the
+// signature on its own can turn up by chance in compressed image data. Data that isn't a PNG,
+// a chunk with a bad CRC or a stream ending part way through an image is an error.
+func CutPNGsFromStream(out chan<- []byte, r io.Reader) error {
+	defer close(out)
+	br := bufio.NewReader(r)
+	for {
+		if _, err := br.Peek(1); err == io.EOF {
+			return nil
+		}
+		img, err := readPNG(br)
+		if err != nil {
+			return err
+		}
+		out <- img
+	}
+}
+
+// readPNG reads the signature and chunks of one PNG up to its IEND chunk
+func readPNG(br *bufio.Reader) ([]byte, error) {
+	sig := make([]byte, len(PNGHead))
+	if _, err := io.ReadFull(br, sig); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if !bytes.Equal(sig, PNGHead) {
+		return nil, errors.New("png: image doesn't start with a PNG signature")
+	}
+	img := bytes.NewBuffer(sig)
+
+	for {
+		// each chunk is its length, its type, its data then a CRC of the type and data
+		var head [8]byte
+		if _, err := io.ReadFull(br, head[:]); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		kind := head[4:]
+		n := binary.BigEndian.Uint32(head[:4])
+		if n > maxPNGChunk {
+			return nil, fmt.Errorf("png: %q chunk is %d bytes long", kind, n)
+		}
+		img.Write(head[:])
+
+		crc := crc32.NewIEEE()
+		crc.Write(kind)
+		if _, err := io.CopyN(io.MultiWriter(img, crc), br, int64(n)); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		var sum [4]byte
+		if _, err := io.ReadFull(br, sum[:]); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if binary.BigEndian.Uint32(sum[:]) != crc.Sum32() {
+			return nil, fmt.Errorf("png: bad CRC on %q chunk", kind)
+		}
+		img.Write(sum[:])
+
+		if string(kind) == "IEND" {
+			return img.Bytes(), nil
+		}
+	}
+}
+
+// CutJPEGsFromStream sends each JPEG of an MJPEG stream down out, closing it when the stream
+// ends. The images are cut by walking their segments, as the end of image marker on its own
+// can turn up early in an embedded thumbnail. A stream ending part way through an image is
+// an error.
+func CutJPEGsFromStream(out chan<- []byte, r io.Reader) error {
+	defer close(out)
+	br := bufio.NewReader(r)
+	for {
+		if _, err := br.Peek(1); err == io.EOF {
+			return nil
+		}
+		img, err := readJPEG(br)
+		if err != nil {
+			return err
+		}
+		out <- img
+	}
+}
+
+// readJPEG reads the segments of one JPEG up to its end of image marker
+func readJPEG(br *bufio.Reader) ([]byte, error) {
+	var img bytes.Buffer
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if b != 0xff {
+			return nil, fmt.Errorf("mjpeg: want a marker, got %#x", b)
+		}
+		// any number of fill bytes may come before the marker
+		marker := byte(0xff)
+		for marker == 0xff && err == nil {
+			marker, err = br.ReadByte()
+		}
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if img.Len() == 0 && marker != jpegSOI {
+			return nil, errors.New("mjpeg: image doesn't start with a start of image marker")
+		}
+		img.Write([]byte{0xff, marker})
+
+		switch {
+		case marker == jpegEOI:
+			return img.Bytes(), nil
+		case marker == jpegSOI || marker == jpegTEM || isRST(marker):
+			// markers without a segment
+			continue
+		}
+
+		var length [2]byte
+		if _, err = io.ReadFull(br, length[:]); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		n := int64(binary.BigEndian.Uint16(length[:]))
+		if n < 2 {
+			return nil, fmt.Errorf("mjpeg: segment %#x is %d bytes long", marker, n)
+		}
+		img.Write(length[:])
+		if _, err = io.CopyN(&img, br, n-2); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+
+		if marker == jpegSOS {
+			if err = copyScan(&img, br); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// copyScan copies the entropy coded data following a start of scan segment, up to the next marker.
+// Any 0xff in the data is followed by a 0 so it can't be mistaken for one, restart markers aside.
+func copyScan(img *bytes.Buffer, br *bufio.Reader) error {
+	for {
+		p, err := br.Peek(2)
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		if p[0] != 0xff {
+			img.WriteByte(p[0])
+			_, _ = br.Discard(1)
+			continue
+		}
+		if p[1] != 0 && !isRST(p[1]) {
+			return nil
+		}
+		img.Write(p)
+		_, _ = br.Discard(2)
+	}
+}
+
+// isRST reports whether the marker is one of the eight restart markers
+func isRST(marker byte) bool {
+	return marker >= 0xd0 && marker <= 0xd7
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
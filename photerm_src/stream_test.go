@@ -0,0 +1,141 @@
+package photerm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"testing"
+)
+
+// cut runs a splitter over data, collecting the images it sends
+func cut(split func(chan<- []byte, io.Reader) error, data []byte) ([][]byte, error) {
+	out := make(chan []byte)
+	errs := make(chan error, 1)
+	go func() { errs <- split(out, bytes.NewReader(data)) }()
+
+	var images [][]byte
+	for img := range out {
+		images = append(images, img)
+	}
+	return images, <-errs
+}
+
+// withSignatureInside encodes the test image with a text chunk holding a PNG signature
+func withSignatureInside(t *testing.T) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage()); err != nil {
+		t.Fatal(err)
+	}
+	data := append([]byte("Comment\x00"), PNGHead...)
+	text := make([]byte, 8, len(data)+12)
+	binary.BigEndian.PutUint32(text, uint32(len(data)))
+	copy(text[4:], "tEXt")
+	text = append(text, data...)
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc32.ChecksumIEEE(text[4:]))
+	text = append(text, sum[:]...)
+
+	// the text chunk goes straight after IHDR, which is 25 bytes after the signature
+	at := len(PNGHead) + 25
+	encoded := buf.Bytes()
+	return append(append(append([]byte{}, encoded[:at]...), text...), encoded[at:]...)
+}
+
+func TestCutPNGsFromStream(t *testing.T) {
+	var first, second bytes.Buffer
+	if err := png.Encode(&first, testImage()); err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(&second, image.NewGray(image.Rect(0, 0, 3, 1))); err != nil {
+		t.Fatal(err)
+	}
+	tricky := withSignatureInside(t)
+	want := [][]byte{first.Bytes(), tricky, second.Bytes()}
+
+	images, err := cut(CutPNGsFromStream, bytes.Join(want, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(images) != len(want) {
+		t.Fatalf("got %d images, want %d", len(images), len(want))
+	}
+	for i := range want {
+		if !bytes.Equal(images[i], want[i]) {
+			t.Errorf("image %d was cut in the wrong place", i)
+		}
+	}
+	if _, err := png.Decode(bytes.NewReader(images[1])); err != nil {
+		t.Errorf("the image with a signature inside doesn't decode: %s", err)
+	}
+}
+
+func TestCutPNGsFromStreamMalformed(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage()); err != nil {
+		t.Fatal(err)
+	}
+	img := buf.Bytes()
+	corrupt := append([]byte{}, img...)
+	corrupt[len(PNGHead)+10] ^= 0xff
+
+	cases := []struct {
+		name   string
+		data   []byte
+		images int
+		is     error
+	}{
+		{"truncated", append(append([]byte{}, img...), img[:len(img)-5]...), 1, io.ErrUnexpectedEOF},
+		{"truncated signature", append(append([]byte{}, img...), img[:4]...), 1, io.ErrUnexpectedEOF},
+		{"bad crc", corrupt, 0, nil},
+		{"not a png", append(append([]byte{}, img...), "junk"...), 1, nil},
+	}
+	for _, c := range cases {
+		images, err := cut(CutPNGsFromStream, c.data)
+		if err == nil {
+			t.Errorf("%s: no error", c.name)
+		} else if c.is != nil && !errors.Is(err, c.is) {
+			t.Errorf("%s: got %v, want %v", c.name, err, c.is)
+		}
+		if len(images) != c.images {
+			t.Errorf("%s: got %d images before the error, want %d", c.name, len(images), c.images)
+		}
+	}
+
+	if images, err := cut(CutPNGsFromStream, nil); err != nil || len(images) != 0 {
+		t.Errorf("empty stream: got %d images and %v", len(images), err)
+	}
+}
+
+func TestCutJPEGsFromStream(t *testing.T) {
+	var want [][]byte
+	for _, img := range []image.Image{testImage(), image.NewGray(image.Rect(0, 0, 9, 3))} {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, nil); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, buf.Bytes())
+	}
+	stream := bytes.Join(want, nil)
+
+	images, err := cut(CutJPEGsFromStream, stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(images) != len(want) {
+		t.Fatalf("got %d images, want %d", len(images), len(want))
+	}
+	for i := range want {
+		if !bytes.Equal(images[i], want[i]) {
+			t.Errorf("image %d was cut in the wrong place", i)
+		}
+	}
+
+	if _, err := cut(CutJPEGsFromStream, stream[:len(stream)-10]); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("truncated: got %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
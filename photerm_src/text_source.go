@@ -59,6 +59,7 @@ func (tf *TypeFace) ImageWidth() int {
 // GenWords takes text and sends each word down the pipe one by one.
 func GenWords(text string) <-chan string {
 	work := func(out chan<- string, txt string) {
+		defer Guard()
 		defer close(out)
 		for found := true; found; {
 			var word, rest string
@@ -78,6 +79,7 @@ func GenWords(text string) <-chan string {
 func GenFixedWidth(text string, width int) <-chan string {
 	text = strings.ReplaceAll(text, "\t", "    ")
 	work := func(out chan<- string, w int, t string) {
+		defer Guard()
 		defer close(out)
 		glyphs := []rune(t)
 		for i := 0; i < len(glyphs)-w; i++ {
@@ -154,6 +156,7 @@ func LefToRightConcat(imgs ...draw.Image) (concat draw.Image, err error) {
 // This buffer transports image.Image instances that are the png rendered text using the TypeFace.
 func GenImages(text <-chan string, pts float64, panningStep int) <-chan image.Image {
 	work := func(res chan<- image.Image, txt <-chan string, tf *TypeFace) {
+		defer Guard()
 		defer close(res)
 		const smoothing = 2
 		imgs := []draw.Image{}
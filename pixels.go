@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"wombatlord/photerm/photerm_src"
+	"wombatlord/photerm/src/dither"
+	"wombatlord/photerm/src/iterm"
+	"wombatlord/photerm/src/kitty"
+	"wombatlord/photerm/src/sixel"
+	"wombatlord/photerm/src/term"
+	"wombatlord/photerm/src/util"
+)
+
+// shadeRegion copies the region r of a frame into a new image with the colour adjustments applied.
+// This is the starting point for the backends that send real pixels to the terminal.
+func shadeRegion(img image.Image, r photerm.Region) *image.RGBA {
+	out := image.NewRGBA(image.Rect(0, 0, r.Right-r.Left, r.Btm-r.Top))
+	for y := r.Top; y < r.Btm; y++ {
+		for x := r.Left; x < r.Right; x++ {
+			out.SetRGBA(x-r.Left, y-r.Top, shade(color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)))
+		}
+	}
+	return out
+}
+
+// reserveLines scrolls the lines an image is about to cover into view, leaving the cursor where it
+// was. Otherwise an image at the bottom of the screen would scroll the terminal as it's drawn.
+func reserveLines(writer io.Writer, rows int) error {
+	// moving up 0 lines moves up 1 as far as terminals are concerned
+	if rows == 0 {
+		return nil
+	}
+	_, err := fmt.Fprint(writer, strings.Repeat("\n", rows), util.MoveUp(rows))
+	return err
+}
+
+// EncodeSixel writes the region r of a frame as sixel graphics. The cursor position is saved
+// first so the savedCursorHooks can return to it.
+func EncodeSixel(writer io.Writer, img image.Image, _ CharPalette, r photerm.Region) (lines int, err error) {
+	_, lines = cellsOf(photerm.Sixel, r)
+	if err = reserveLines(writer, lines); err != nil {
+		return 0, err
+	}
+	if _, err = fmt.Fprint(writer, util.SaveCursor()); err != nil {
+		return 0, err
+	}
+
+	enc := sixel.NewEncoder(writer)
+	// dithering against an adaptive palette is subtle, so only the error diffusion methods are worth it
+	if method, ok := dither.Methods[Args.Dither].(dither.Diffusion); ok {
+		enc.Dither = method
+	}
+	return lines, enc.Encode(shadeRegion(img, r))
+}
+
+// savedCursorHooks replace seeking back by line count with restoring the cursor saved by the
+// encoder, since the terminal, not photerm, decides where the cursor goes after an image.
+var savedCursorHooks = FrameEndHooks{
+	Print: func(writer io.Writer, _ int) error {
+		_, err := fmt.Fprintln(writer)
+		return err
+	},
+	Animate: func(writer io.Writer, _ int) error {
+		_, err := fmt.Fprint(writer, util.RestoreCursor())
+		return err
+	},
+}
+
+// kittyAnimationID is the id every animation frame is sent with, so each replaces the last.
+// Stills are sent without one, so they can't replace what another run left on screen.
+const kittyAnimationID = 1
+
+// DeleteKittyAnimation removes the animation's image from the terminal, which would otherwise
+// keep it in its store after playback has ended and the alternate screen it was on is gone.
+func DeleteKittyAnimation(writer io.Writer) error {
+	return kitty.NewEncoder(writer, kittyAnimationID).Delete()
+}
+
+// queryTimeout is how long to wait for the terminal to answer a query
+const queryTimeout = 250 * time.Millisecond
+
+// KittySupported asks the terminal whether it speaks the kitty graphics protocol. When stdout isn't
+// a terminal there's nobody to ask, so the user is taken at their word.
+func KittySupported() bool {
+	if !term.IsTerminal(os.Stdout) {
+		return true
+	}
+	tty, err := term.Open()
+	if err != nil {
+		return true
+	}
+	defer tty.Close()
+
+	supported, err := kitty.Query(tty, queryTimeout)
+	return supported && err == nil
+}
+
+// cellsOf returns how many terminal cells the region r covers with the renderer's packing, rounding up
+func cellsOf(renderer photerm.Renderer, r photerm.Region) (cols, rows int) {
+	px, py := renderer.Packing()
+	return (r.Right - r.Left + px - 1) / px, (r.Btm - r.Top + py - 1) / py
+}
+
+// KittyEncoder returns a FrameEncoder writing the region r of a frame with the kitty graphics
+// protocol, sending the image with the id given, 0 for none. The image doesn't move the cursor,
+// so the lines it covers are scrolled into view beforehand.
+func KittyEncoder(imageID int) FrameEncoder {
+	return func(writer io.Writer, img image.Image, _ CharPalette, r photerm.Region) (lines int, err error) {
+		cols, rows := cellsOf(photerm.Kitty, r)
+		if err = reserveLines(writer, rows); err != nil {
+			return 0, err
+		}
+		return rows, kitty.NewEncoder(writer, imageID).Encode(shadeRegion(img, r), cols, rows)
+	}
+}
+
+// kittyFrameEndHooks step past the image for stills, animation frames are replaced in place so
+// there's nowhere to go.
+var kittyFrameEndHooks = FrameEndHooks{
+	Print: func(writer io.Writer, lines int) error {
+		_, err := fmt.Fprintln(writer, util.MoveDown(lines))
+		return err
+	},
+	Animate: func(io.Writer, int) error {
+		return nil
+	},
+}
+
+// EncodeITerm writes the region r of a frame as an iTerm2 inline image, saving the cursor
+// position first for the savedCursorHooks.
+func EncodeITerm(writer io.Writer, img image.Image, _ CharPalette, r photerm.Region) (lines int, err error) {
+	cols, rows := cellsOf(photerm.ITerm, r)
+	if err = reserveLines(writer, rows); err != nil {
+		return 0, err
+	}
+	if _, err = fmt.Fprint(writer, util.SaveCursor()); err != nil {
+		return 0, err
+	}
+	return rows, iterm.Encode(writer, shadeRegion(img, r), cols, rows)
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"wombatlord/photerm/photerm_src"
+	"wombatlord/photerm/src/util"
+)
+
+// ErrSessionEnded is returned when writing to a Session that has been closed
+var ErrSessionEnded = errors.New("playback session has ended")
+
+// cancel aborts any escape sequence the terminal is part way through, in case playback
+// was interrupted in the middle of writing one
+const cancel = "\x18"
+
+// closeTimeout is how long a signal waits for the terminal to be restored before exiting anyway.
+// A terminal that has stopped reading, say after ^S, blocks the frame being written, and the
+// restoring sequence can only follow it.
+const closeTimeout = time.Second
+
+// Session is a stretch of animation played in the alternate screen with the cursor hidden.
+// However playback ends, by finishing, SIGINT, SIGTERM, log.Fatal, or a panic in the main
+// goroutine followed by Close or in one of the pipeline's goroutines, the terminal is put back
+// how it was found. When the terminal is resized the screen is cleared and the next frame
+// drawn afresh.
+type Session struct {
+	mu      sync.Mutex
+	out     io.Writer
+	signals chan os.Signal
+	ended   bool
+	// logs is where the log package wrote before the session started
+	logs io.Writer
+	// resized is set when the terminal changes size, and cleared by the next frame
+	resized int32
+	// cleanup, if set, is written before the terminal is restored
+	cleanup func(writer io.Writer) error
+}
+
+// sessionLog is the log package's output during a session. Everything photerm logs during
+// playback is fatal, and log.Fatal exits without running deferred calls, so the session is
+// closed before the message is written, which also leaves it on the normal screen to be read.
+// Closing from here leaves the log package's output alone, as its lock is held while writing.
+type sessionLog struct {
+	s *Session
+}
+
+func (l sessionLog) Write(p []byte) (int, error) {
+	l.s.end()
+	return l.s.logs.Write(p)
+}
+
+// StartSession switches out to the alternate screen and starts watching for signals.
+// Writes to the Session are passed on to out until it's closed. onResize, if set,
+// is called whenever the terminal changes size. cleanup, if set, writes whatever the backend
+// needs removed from the terminal once playback ends.
+func StartSession(out io.Writer, onResize func(), cleanup func(writer io.Writer) error) (*Session, error) {
+	s := &Session{out: out, signals: make(chan os.Signal, 1), logs: log.Writer(), cleanup: cleanup}
+	// the line above the frame is left free, the animate hook seeks back to it
+	if _, err := fmt.Fprint(out, util.EnterAltScreen(), util.HideCursor(), util.MoveTo(2, 1)); err != nil {
+		return nil, err
+	}
+
+	log.SetOutput(sessionLog{s})
+	photerm.OnPanic(func(interface{}) { s.Close() })
+	signal.Notify(s.signals, append(resizeSignals, syscall.SIGINT, syscall.SIGTERM)...)
+	go func() {
+		defer photerm.Guard()
+		for sig := range s.signals {
+			if sig != syscall.SIGINT && sig != syscall.SIGTERM {
+				atomic.StoreInt32(&s.resized, 1)
+				if onResize != nil {
+					onResize()
+				}
+				continue
+			}
+			closed := make(chan struct{})
+			go func() {
+				s.Close()
+				close(closed)
+			}()
+			select {
+			case <-closed:
+			case <-time.After(closeTimeout):
+			}
+			os.Exit(128 + int(sig.(syscall.Signal)))
+		}
+	}()
+	return s, nil
+}
+
+// Write passes p on to the terminal, unless the session has ended
+func (s *Session) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return 0, ErrSessionEnded
+	}
+	return s.out.Write(p)
+}
+
+// Close restores the terminal and the log package's output. It's safe to call more than once,
+// and from any goroutine.
+func (s *Session) Close() error {
+	err := s.end()
+	photerm.OnPanic(nil)
+	log.SetOutput(s.logs)
+	return err
+}
+
+// end restores the terminal, once
+func (s *Session) end() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return nil
+	}
+	s.ended = true
+	signal.Stop(s.signals)
+	close(s.signals)
+
+	// anything half written is cancelled before the cleanup is sent
+	_, err := fmt.Fprint(s.out, cancel, util.EndSync())
+	if err == nil && s.cleanup != nil {
+		err = s.cleanup(s.out)
+	}
+	// the terminal is restored whether or not the cleanup could be sent
+	if _, restoreErr := fmt.Fprint(s.out, Normalizer, util.ShowCursor(), util.ExitAltScreen()); err == nil {
+		err = restoreErr
+	}
+	return err
+}
+
+// Frame returns a FrameEncoder that starts each frame with a synchronized update, the terminal
+// then draws nothing until the matching FrameEnd hook, which stops frames tearing.
+// Frames are encoded by an encoder from newEncoder. After the terminal is resized, or when a
+// frame isn't the size of the last, the screen is cleared and a fresh encoder made, so nothing
+// is assumed about what was on screen.
+func (s *Session) Frame(newEncoder func() FrameEncoder) FrameEncoder {
+	encode := newEncoder()
+	var bounds image.Rectangle
+	return func(writer io.Writer, img image.Image, palette CharPalette, r photerm.Region) (int, error) {
+		if _, err := io.WriteString(writer, util.BeginSync()); err != nil {
+			return 0, err
+		}
+		if atomic.SwapInt32(&s.resized, 0) == 1 || img.Bounds() != bounds {
+			bounds = img.Bounds()
+			if _, err := fmt.Fprint(writer, util.ClearEntireScreen(), util.MoveTo(2, 1)); err != nil {
+				return 0, err
+			}
+			encode = newEncoder()
+		}
+		return encode(writer, img, palette, r)
+	}
+}
+
+// FrameEnd wraps hook to finish the synchronized update started by Frame
+func (s *Session) FrameEnd(hook FrameEndHook) FrameEndHook {
+	return func(writer io.Writer, seekBack int) error {
+		if err := hook(writer, seekBack); err != nil {
+			return err
+		}
+		_, err := io.WriteString(writer, util.EndSync())
+		return err
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package main
+
+import "os"
+
+// resizeSignals are the signals sent when the terminal changes size
+var resizeSignals []os.Signal
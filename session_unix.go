@@ -0,0 +1,11 @@
+//go:build linux || darwin
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// resizeSignals are the signals sent when the terminal changes size
+var resizeSignals = []os.Signal{syscall.SIGWINCH}
@@ -0,0 +1,117 @@
+package adjust
+
+import (
+	"image/color"
+	"math"
+
+	"wombatlord/photerm/src/colour"
+)
+
+// Adjustment changes a colour. Adjustments are pure functions of their parameters, so one
+// can be shared between goroutines, and a new one made for every frame costs next to nothing.
+// A nil Adjustment changes nothing.
+type Adjustment func(c color.RGBA) color.RGBA
+
+// Chain returns the adjustment making each of adjs in turn, skipping any that are nil.
+// When there's nothing left to do Chain returns nil.
+func Chain(adjs ...Adjustment) Adjustment {
+	steps := make([]Adjustment, 0, len(adjs))
+	for _, adj := range adjs {
+		if adj != nil {
+			steps = append(steps, adj)
+		}
+	}
+	switch len(steps) {
+	case 0:
+		return nil
+	case 1:
+		return steps[0]
+	}
+	return func(c color.RGBA) color.RGBA {
+		for _, adj := range steps {
+			c = adj(c)
+		}
+		return c
+	}
+}
+
+// Apply makes the adjustment to c, nil leaving c as it is.
+func (adj Adjustment) Apply(c color.RGBA) color.RGBA {
+	if adj == nil {
+		return c
+	}
+	return adj(c)
+}
+
+// Polar changes a colour in OKLCH. A nil Polar changes nothing.
+type Polar func(c colour.LCh) colour.LCh
+
+// InOKLCH returns the adjustment making each of fs in turn, converting the colour to OKLCH
+// and back only the once. Nils are skipped, and with nothing left to do InOKLCH returns nil.
+func InOKLCH(fs ...Polar) Adjustment {
+	steps := make([]Polar, 0, len(fs))
+	for _, f := range fs {
+		if f != nil {
+			steps = append(steps, f)
+		}
+	}
+	if len(steps) == 0 {
+		return nil
+	}
+	return func(c color.RGBA) color.RGBA {
+		lch := colour.OKLab(c).LCh()
+		for _, f := range steps {
+			lch = f(lch)
+		}
+		out := lch.Lab().RGBA()
+		out.A = c.A
+		return out
+	}
+}
+
+// Hue turns the hue by radians. Unlike turning about the grey axis of RGB, how light
+// the colour looks is unchanged.
+func Hue(radians float64) Polar {
+	if radians == 0 {
+		return nil
+	}
+	return func(c colour.LCh) colour.LCh {
+		c.H += radians
+		return c
+	}
+}
+
+// Saturation multiplies the chroma by k, 0 leaving only grey.
+func Saturation(k float64) Polar {
+	if k == 1 {
+		return nil
+	}
+	return func(c colour.LCh) colour.LCh {
+		c.C *= math.Max(0, k)
+		return c
+	}
+}
+
+// maxChroma is about as colourful as sRGB gets in OKLCH
+const maxChroma = 0.32
+
+// Vibrance is saturation that holds back on colours that are colourful already,
+// so skin tones and skies don't blow out. Negative amounts mute instead.
+func Vibrance(amount float64) Polar {
+	if amount == 0 {
+		return nil
+	}
+	return func(c colour.LCh) colour.LCh {
+		muted := math.Max(0, 1-c.C/maxChroma)
+		c.C *= math.Max(0, 1+amount*muted)
+		return c
+	}
+}
+
+// Grayscale drains amount, from 0 to 1, of the colour out, keeping how light it looks.
+func Grayscale(amount float64) Polar {
+	if amount == 0 {
+		return nil
+	}
+	return Saturation(1 - math.Max(0, math.Min(1, amount)))
+}
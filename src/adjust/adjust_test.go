@@ -0,0 +1,66 @@
+package adjust
+
+import (
+	"image/color"
+	"math"
+	"testing"
+)
+
+// near reports whether every channel of a and b is within one step, leaving room
+// for rounding on the way in and out of OKLab
+func near(a, b color.RGBA) bool {
+	d := func(x, y uint8) bool { return math.Abs(float64(x)-float64(y)) <= 1 }
+	return d(a.R, b.R) && d(a.G, b.G) && d(a.B, b.B) && a.A == b.A
+}
+
+func TestAdjustments(t *testing.T) {
+	orange := color.RGBA{R: 200, G: 100, B: 50, A: 255}
+	brown := color.RGBA{R: 100, G: 50, B: 20, A: 128}
+	cases := []struct {
+		name string
+		adj  Adjustment
+		in   color.RGBA
+		want color.RGBA
+	}{
+		// the grey keeps the lightness in OKLab, L cubed is the linear light of the grey
+		{"desaturate", InOKLCH(Saturation(0)), orange, color.RGBA{R: 132, G: 132, B: 132, A: 255}},
+		{"grayscale", InOKLCH(Grayscale(1)), orange, color.RGBA{R: 132, G: 132, B: 132, A: 255}},
+		{"half saturation", InOKLCH(Saturation(0.5)), orange, color.RGBA{R: 169, G: 119, B: 96, A: 255}},
+		{"quarter turn", InOKLCH(Hue(math.Pi / 2)), orange, color.RGBA{R: 92, G: 151, B: 55, A: 255}},
+		// the opposite hue is out of gamut and clipped
+		{"half turn", InOKLCH(Hue(math.Pi)), orange, color.RGBA{G: 148, B: 196, A: 255}},
+		{"full turn", InOKLCH(Hue(2 * math.Pi)), orange, orange},
+		{"grey has no hue", InOKLCH(Hue(1)), color.RGBA{R: 90, G: 90, B: 90, A: 255}, color.RGBA{R: 90, G: 90, B: 90, A: 255}},
+		{"vibrance", InOKLCH(Vibrance(1)), orange, color.RGBA{R: 231, G: 66, A: 255}},
+		{"sepia", Sepia(1).Adjustment(), brown, color.RGBA{R: 82, G: 73, B: 57, A: 128}},
+		{"half sepia", Sepia(0.5).Adjustment(), brown, color.RGBA{R: 91, G: 61, B: 38, A: 128}},
+		{"sepia clips", Sepia(1).Adjustment(), color.RGBA{R: 255, G: 255, B: 255, A: 255}, color.RGBA{R: 255, G: 255, B: 239, A: 255}},
+		{"in turn", Chain(InOKLCH(Saturation(0)), Sepia(1).Adjustment()), brown, Sepia(1).Adjustment()(InOKLCH(Saturation(0))(brown))},
+	}
+	for _, c := range cases {
+		if got := c.adj.Apply(c.in); !near(got, c.want) {
+			t.Errorf("%s: %v came out as %v, want %v", c.name, c.in, got, c.want)
+		}
+	}
+}
+
+func TestNothingToDo(t *testing.T) {
+	nils := map[string]Adjustment{
+		"hue":        InOKLCH(Hue(0)),
+		"saturation": InOKLCH(Saturation(1)),
+		"vibrance":   InOKLCH(Vibrance(0)),
+		"grayscale":  InOKLCH(Grayscale(0)),
+		"sepia":      Sepia(0).Adjustment(),
+		"identity":   IdentityMatrix.Adjustment(),
+		"chain":      Chain(nil, Sepia(0).Adjustment()),
+	}
+	for name, adj := range nils {
+		if adj != nil {
+			t.Errorf("%s isn't nil", name)
+		}
+	}
+	c := color.RGBA{R: 1, G: 2, B: 3, A: 4}
+	if got := Adjustment(nil).Apply(c); got != c {
+		t.Errorf("nil changed %v to %v", c, got)
+	}
+}
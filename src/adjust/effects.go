@@ -0,0 +1,69 @@
+package adjust
+
+import (
+	"image/color"
+	"math"
+	"time"
+
+	"wombatlord/photerm/src/colour"
+)
+
+// Shader is an adjustment that depends on where the pixel is.
+type Shader func(x, y int, c color.RGBA) color.RGBA
+
+// Effects are colour adjustments that change over the course of an animation.
+type Effects struct {
+	// HueCycle turns the hue this many radians a second
+	HueCycle float64
+	// Rainbow tints the frame with this many rainbows across its width, 0 for none.
+	// The rainbows move with HueCycle.
+	Rainbow float64
+	// Pulse drains the colour and brings it back this many times a second
+	Pulse float64
+}
+
+// None reports whether the effects do nothing.
+func (e Effects) None() bool {
+	return e == Effects{}
+}
+
+// rainbowLightness and rainbowChroma pick the colours of the rainbow from OKLCH,
+// so every colour looks about as bright as the next
+const (
+	rainbowLightness = 0.75
+	rainbowChroma    = 0.15
+)
+
+// At returns the shader for the moment t of the animation, for frames width pixels wide.
+// The shader is pure, so can be shared between goroutines.
+func (e Effects) At(t time.Duration, width int) Shader {
+	secs := t.Seconds()
+	hue := e.HueCycle * secs
+	turn := InOKLCH(Hue(hue))
+	var pulse Adjustment
+	if e.Pulse != 0 {
+		pulse = InOKLCH(Saturation((1 + math.Cos(2*math.Pi*e.Pulse*secs)) / 2))
+	}
+
+	if e.Rainbow == 0 || width == 0 {
+		adj := Chain(turn, pulse)
+		return func(_, _ int, c color.RGBA) color.RGBA {
+			return adj.Apply(c)
+		}
+	}
+
+	// a colour for each column, each pixel is then filtered through its column's colour
+	tints := make([]color.RGBA, width)
+	for x := range tints {
+		h := hue + 2*math.Pi*e.Rainbow*float64(x)/float64(width)
+		tints[x] = colour.LCh{L: rainbowLightness, C: rainbowChroma, H: h}.Lab().RGBA()
+	}
+	return func(x, _ int, c color.RGBA) color.RGBA {
+		c = turn.Apply(c)
+		tint := tints[((x%width)+width)%width]
+		c.R = uint8(int(c.R) * int(tint.R) / 255)
+		c.G = uint8(int(c.G) * int(tint.G) / 255)
+		c.B = uint8(int(c.B) * int(tint.B) / 255)
+		return pulse.Apply(c)
+	}
+}
@@ -0,0 +1,55 @@
+package adjust
+
+import (
+	"image/color"
+	"math"
+)
+
+// Matrix is an affine colour matrix over gamma encoded RGB from 0 to 1. Each row gives
+// an output channel as a weighted sum of the red, green and blue inputs plus the offset
+// in the last column.
+type Matrix [3][4]float64
+
+// IdentityMatrix is the matrix that changes nothing.
+var IdentityMatrix = Matrix{
+	{1, 0, 0, 0},
+	{0, 1, 0, 0},
+	{0, 0, 1, 0},
+}
+
+// Mix returns the matrix doing amount, from 0 to 1, of m.
+func (m Matrix) Mix(amount float64) (out Matrix) {
+	for row := range m {
+		for col := range m[row] {
+			out[row][col] = IdentityMatrix[row][col] + (m[row][col]-IdentityMatrix[row][col])*amount
+		}
+	}
+	return out
+}
+
+// Adjustment returns m as an Adjustment, nil when m is the identity.
+func (m Matrix) Adjustment() Adjustment {
+	if m == IdentityMatrix {
+		return nil
+	}
+	return func(c color.RGBA) color.RGBA {
+		r, g, b := float64(c.R)/255, float64(c.G)/255, float64(c.B)/255
+		channel := func(row [4]float64) uint8 {
+			v := row[0]*r + row[1]*g + row[2]*b + row[3]
+			return uint8(math.Max(0, math.Min(1, v))*255 + 0.5)
+		}
+		return color.RGBA{R: channel(m[0]), G: channel(m[1]), B: channel(m[2]), A: c.A}
+	}
+}
+
+// sepia is the usual sepia toning matrix
+var sepia = Matrix{
+	{0.393, 0.769, 0.189, 0},
+	{0.349, 0.686, 0.168, 0},
+	{0.272, 0.534, 0.131, 0},
+}
+
+// Sepia tones amount, from 0 to 1, of the colour to the browns of an old photograph.
+func Sepia(amount float64) Matrix {
+	return sepia.Mix(math.Max(0, math.Min(1, amount)))
+}
@@ -0,0 +1,313 @@
+package apng
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/draw"
+	"image/png"
+	"io"
+	"time"
+)
+
+// Signature starts every PNG, animated or not.
+const Signature = "\x89PNG\r\n\x1a\n"
+
+// maxPixels is the most pixels an image may have, and maxAnimationPixels the most over every
+// frame of an animation, as each frame is kept whole. They stop a few bytes of header
+// asking for gigabytes.
+const (
+	maxPixels          = 1 << 26
+	maxAnimationPixels = 1 << 28
+)
+
+// Dispose ops say what happens to a frame's region before the next frame is drawn.
+const (
+	DisposeNone       = 0
+	DisposeBackground = 1
+	DisposePrevious   = 2
+)
+
+// Blend ops say how a frame is drawn over what's already there.
+const (
+	BlendSource = 0
+	BlendOver   = 1
+)
+
+// Frame is a whole frame of the animation, already composited over the frames before it.
+type Frame struct {
+	Image image.Image
+	// Delay is how long the frame is shown for, 0 means move on as soon as possible
+	Delay time.Duration
+}
+
+// APNG is a decoded animated PNG.
+type APNG struct {
+	Frames []Frame
+	// Plays is how many times the animation plays, 0 for forever
+	Plays int
+}
+
+// frameControl is the contents of an fcTL chunk
+type frameControl struct {
+	width, height, x, y uint32
+	delayNum, delayDen  uint16
+	dispose, blend      byte
+}
+
+// region returns the part of the canvas the frame covers
+func (fc frameControl) region() image.Rectangle {
+	return image.Rect(int(fc.x), int(fc.y), int(fc.x+fc.width), int(fc.y+fc.height))
+}
+
+// delay returns the delay of the frame, a denominator of 0 meaning hundredths of a second
+func (fc frameControl) delay() time.Duration {
+	den := time.Duration(fc.delayDen)
+	if den == 0 {
+		den = 100
+	}
+	return time.Duration(fc.delayNum) * time.Second / den
+}
+
+// rawFrame is a frame before decoding, its control and the image data from its IDAT or fdAT chunks
+type rawFrame struct {
+	control frameControl
+	data    [][]byte
+}
+
+// DecodeAll reads an animated PNG, compositing the frames according to their blend and dispose ops.
+// A PNG that isn't animated is a single frame, decoded by the png package as it is.
+func DecodeAll(r io.Reader) (*APNG, error) {
+	br := bufio.NewReader(r)
+	// what's read is kept until it's clear whether the PNG is animated, to replay to the png package
+	var head bytes.Buffer
+	in := io.TeeReader(br, &head)
+	sig := make([]byte, len(Signature))
+	if _, err := io.ReadFull(in, sig); err != nil {
+		return nil, err
+	}
+	if string(sig) != Signature {
+		return nil, errors.New("apng: not a PNG")
+	}
+
+	var (
+		header   []byte
+		extras   [][]byte
+		animated bool
+		plays    int
+		frames   []*rawFrame
+		current  *rawFrame
+		still    = &rawFrame{}
+		seenIDAT bool
+	)
+	for {
+		kind, data, err := readChunk(in)
+		if err != nil {
+			return nil, err
+		}
+		switch kind {
+		case "IHDR":
+			if len(data) != 13 {
+				return nil, errors.New("apng: bad IHDR")
+			}
+			header = data
+			still.control = frameControl{width: binary.BigEndian.Uint32(data), height: binary.BigEndian.Uint32(data[4:])}
+			if still.control.width == 0 || still.control.height == 0 ||
+				uint64(still.control.width)*uint64(still.control.height) > maxPixels {
+				return nil, fmt.Errorf("apng: can't decode a %dx%d image", still.control.width, still.control.height)
+			}
+		case "PLTE", "tRNS":
+			// every frame shares the colours of the default image
+			extras = append(extras, chunk(kind, data))
+		case "acTL":
+			if len(data) != 8 {
+				return nil, errors.New("apng: bad acTL")
+			}
+			animated = true
+			plays = int(binary.BigEndian.Uint32(data[4:]))
+			in = br
+		case "fcTL":
+			control, err := parseFrameControl(data)
+			if err != nil {
+				return nil, err
+			}
+			current = &rawFrame{control: control}
+			frames = append(frames, current)
+		case "IDAT":
+			if !animated {
+				// the animation control comes before the image data, so this is a plain PNG
+				img, err := png.Decode(io.MultiReader(&head, br))
+				if err != nil {
+					return nil, err
+				}
+				return &APNG{Frames: []Frame{{Image: img}}}, nil
+			}
+			seenIDAT = true
+			// the default image is only part of the animation when a frame control comes before it
+			still.data = append(still.data, data)
+			if current != nil {
+				current.data = append(current.data, data)
+			}
+		case "fdAT":
+			if current == nil || !seenIDAT || len(data) < 4 {
+				return nil, errors.New("apng: misplaced fdAT")
+			}
+			current.data = append(current.data, data[4:])
+		case "IEND":
+			if header == nil {
+				return nil, errors.New("apng: no IHDR")
+			}
+			if !seenIDAT {
+				return nil, errors.New("apng: no IDAT")
+			}
+			if len(frames) == 0 {
+				img, err := decodeFrame(header, extras, still)
+				if err != nil {
+					return nil, err
+				}
+				return &APNG{Frames: []Frame{{Image: img}}}, nil
+			}
+			return assemble(header, extras, plays, frames)
+		}
+	}
+}
+
+// assemble decodes and composites the frames once every chunk has been read
+func assemble(header []byte, extras [][]byte, plays int, frames []*rawFrame) (*APNG, error) {
+	width, height := binary.BigEndian.Uint32(header), binary.BigEndian.Uint32(header[4:])
+	if uint64(width)*uint64(height)*uint64(len(frames)) > maxAnimationPixels {
+		return nil, fmt.Errorf("apng: can't decode %d %dx%d frames", len(frames), width, height)
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, int(width), int(height)))
+	out := &APNG{Plays: plays, Frames: make([]Frame, 0, len(frames))}
+	for i, raw := range frames {
+		region := raw.control.region()
+		if !region.In(canvas.Bounds()) || region.Empty() {
+			return nil, fmt.Errorf("apng: frame %d lies outside the image", i)
+		}
+		img, err := decodeFrame(header, extras, raw)
+		if err != nil {
+			return nil, fmt.Errorf("apng: frame %d: %w", i, err)
+		}
+
+		dispose := raw.control.dispose
+		if i == 0 && dispose == DisposePrevious {
+			// there's nothing before the first frame to go back to
+			dispose = DisposeBackground
+		}
+		var previous *image.RGBA
+		if dispose == DisposePrevious {
+			previous = image.NewRGBA(region)
+			draw.Draw(previous, region, canvas, region.Min, draw.Src)
+		}
+
+		op := draw.Over
+		if raw.control.blend == BlendSource {
+			op = draw.Src
+		}
+		draw.Draw(canvas, region, img, img.Bounds().Min, op)
+
+		frame := image.NewRGBA(canvas.Bounds())
+		copy(frame.Pix, canvas.Pix)
+		out.Frames = append(out.Frames, Frame{Image: frame, Delay: raw.control.delay()})
+
+		switch dispose {
+		case DisposeBackground:
+			draw.Draw(canvas, region, image.Transparent, image.Point{}, draw.Src)
+		case DisposePrevious:
+			draw.Draw(canvas, region, previous, region.Min, draw.Src)
+		}
+	}
+	return out, nil
+}
+
+// decodeFrame decodes a frame by wrapping its data up as a PNG of its own, with the header
+// of the default image resized to the frame
+func decodeFrame(header []byte, extras [][]byte, raw *rawFrame) (image.Image, error) {
+	var buf bytes.Buffer
+	buf.WriteString(Signature)
+
+	ihdr := append([]byte(nil), header...)
+	binary.BigEndian.PutUint32(ihdr, raw.control.width)
+	binary.BigEndian.PutUint32(ihdr[4:], raw.control.height)
+	buf.Write(chunk("IHDR", ihdr))
+	for _, extra := range extras {
+		buf.Write(extra)
+	}
+	for _, data := range raw.data {
+		buf.Write(chunk("IDAT", data))
+	}
+	buf.Write(chunk("IEND", nil))
+	return png.Decode(&buf)
+}
+
+func parseFrameControl(data []byte) (fc frameControl, err error) {
+	if len(data) != 26 {
+		return fc, errors.New("apng: bad fcTL")
+	}
+	be := binary.BigEndian
+	fc = frameControl{
+		width:    be.Uint32(data[4:]),
+		height:   be.Uint32(data[8:]),
+		x:        be.Uint32(data[12:]),
+		y:        be.Uint32(data[16:]),
+		delayNum: be.Uint16(data[20:]),
+		delayDen: be.Uint16(data[22:]),
+		dispose:  data[24],
+		blend:    data[25],
+	}
+	if fc.dispose > DisposePrevious || fc.blend > BlendOver {
+		return fc, errors.New("apng: bad fcTL ops")
+	}
+	return fc, nil
+}
+
+// readChunk reads the next chunk, checking its CRC. The body is read as it comes rather than
+// allocated up front, so a chunk can't claim more memory than there is data.
+func readChunk(r io.Reader) (kind string, data []byte, err error) {
+	var head [8]byte
+	if _, err = io.ReadFull(r, head[:]); err != nil {
+		return "", nil, unexpected(err)
+	}
+	length := binary.BigEndian.Uint32(head[:4])
+	if length > 0x7fffffff {
+		return "", nil, errors.New("apng: chunk too long")
+	}
+	var buf bytes.Buffer
+	if _, err = io.CopyN(&buf, r, int64(length)+4); err != nil {
+		return "", nil, unexpected(err)
+	}
+	body := buf.Bytes()
+	data, sum := body[:length], binary.BigEndian.Uint32(body[length:])
+
+	crc := crc32.NewIEEE()
+	crc.Write(head[4:])
+	crc.Write(data)
+	if crc.Sum32() != sum {
+		return "", nil, fmt.Errorf("apng: bad CRC on %s chunk", head[4:])
+	}
+	return string(head[4:]), data, nil
+}
+
+// chunk encodes a chunk with its length and CRC
+func chunk(kind string, data []byte) []byte {
+	out := make([]byte, 8, len(data)+12)
+	binary.BigEndian.PutUint32(out, uint32(len(data)))
+	copy(out[4:], kind)
+	out = append(out, data...)
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc32.ChecksumIEEE(out[4:]))
+	return append(out, sum[:]...)
+}
+
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,201 @@
+package apng
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+	"time"
+)
+
+// testPalette is shared by every test frame, as frames have to share the format of the default image
+var testPalette = color.Palette{
+	color.NRGBA{A: 255},
+	color.NRGBA{R: 255, A: 255},
+	color.NRGBA{B: 255, A: 255},
+	color.NRGBA{G: 255, A: 128},
+}
+
+// testFrame is a frame to build an APNG from, filled with a colour of testPalette
+type testFrame struct {
+	fill           uint8
+	rect           image.Rectangle
+	delay          uint16
+	dispose, blend byte
+}
+
+// chunksOf splits an encoded PNG into its chunks, after the signature
+func chunksOf(t *testing.T, img image.Image) (chunks [][2][]byte) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()[len(Signature):]
+	for len(b) > 0 {
+		length := binary.BigEndian.Uint32(b)
+		chunks = append(chunks, [2][]byte{b[4:8], b[8 : 8+length]})
+		b = b[12+length:]
+	}
+	return chunks
+}
+
+// encodeAPNG builds an animated PNG the size of the first frame, which is also the default image
+func encodeAPNG(t *testing.T, frames []testFrame) []byte {
+	out := bytes.NewBufferString(Signature)
+	seq := uint32(0)
+	actl := make([]byte, 8)
+	binary.BigEndian.PutUint32(actl, uint32(len(frames)))
+
+	for i, f := range frames {
+		img := image.NewPaletted(image.Rect(0, 0, f.rect.Dx(), f.rect.Dy()), testPalette)
+		for p := range img.Pix {
+			img.Pix[p] = f.fill
+		}
+		fctl := make([]byte, 26)
+		binary.BigEndian.PutUint32(fctl, seq)
+		binary.BigEndian.PutUint32(fctl[4:], uint32(f.rect.Dx()))
+		binary.BigEndian.PutUint32(fctl[8:], uint32(f.rect.Dy()))
+		binary.BigEndian.PutUint32(fctl[12:], uint32(f.rect.Min.X))
+		binary.BigEndian.PutUint32(fctl[16:], uint32(f.rect.Min.Y))
+		binary.BigEndian.PutUint16(fctl[20:], f.delay)
+		binary.BigEndian.PutUint16(fctl[22:], 1000)
+		fctl[24], fctl[25] = f.dispose, f.blend
+		seq++
+
+		for _, c := range chunksOf(t, img) {
+			kind, data := string(c[0]), c[1]
+			switch {
+			case kind == "IHDR" && i == 0:
+				out.Write(chunk(kind, data))
+				out.Write(chunk("acTL", actl))
+			case (kind == "PLTE" || kind == "tRNS") && i == 0:
+				out.Write(chunk(kind, data))
+			case kind == "IDAT":
+				if fctl != nil {
+					out.Write(chunk("fcTL", fctl))
+					fctl = nil
+				}
+				// the first frame is the default image, the rest go in fdAT chunks
+				if i == 0 {
+					out.Write(chunk(kind, data))
+					continue
+				}
+				fdat := make([]byte, 4, len(data)+4)
+				binary.BigEndian.PutUint32(fdat, seq)
+				out.Write(chunk("fdAT", append(fdat, data...)))
+				seq++
+			}
+		}
+	}
+	out.Write(chunk("IEND", nil))
+	return out.Bytes()
+}
+
+func TestDecodeAll(t *testing.T) {
+	const red, blue, halfGreen = 1, 2, 3
+	data := encodeAPNG(t, []testFrame{
+		{fill: red, rect: image.Rect(0, 0, 4, 4), delay: 100},
+		{fill: blue, rect: image.Rect(1, 1, 3, 3), delay: 250, dispose: DisposeBackground},
+		{fill: halfGreen, rect: image.Rect(0, 0, 2, 2), delay: 0, dispose: DisposePrevious, blend: BlendOver},
+		{fill: halfGreen, rect: image.Rect(3, 3, 4, 4), delay: 50},
+	})
+
+	anim, err := DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anim.Frames) != 4 {
+		t.Fatalf("got %d frames, want 4", len(anim.Frames))
+	}
+
+	rgba := func(c color.Color) color.RGBA { return color.RGBAModel.Convert(c).(color.RGBA) }
+	clear := color.RGBA{}
+	cases := []struct {
+		frame int
+		at    image.Point
+		want  color.RGBA
+		delay time.Duration
+	}{
+		{0, image.Pt(1, 1), rgba(testPalette[red]), 100 * time.Millisecond},
+		{1, image.Pt(1, 1), rgba(testPalette[blue]), 250 * time.Millisecond},
+		{1, image.Pt(0, 0), rgba(testPalette[red]), 250 * time.Millisecond},
+		// the blue square was disposed of to transparency, then blended over
+		{2, image.Pt(1, 1), rgba(testPalette[halfGreen]), 0},
+		{2, image.Pt(0, 0), color.RGBA{R: 127, G: 128, A: 255}, 0},
+		// the green square was disposed of back to what was under it
+		{3, image.Pt(0, 0), rgba(testPalette[red]), 50 * time.Millisecond},
+		{3, image.Pt(1, 1), clear, 50 * time.Millisecond},
+		// the source blend replaces the pixel outright
+		{3, image.Pt(3, 3), rgba(testPalette[halfGreen]), 50 * time.Millisecond},
+	}
+	for _, c := range cases {
+		f := anim.Frames[c.frame]
+		if got := rgba(f.Image.At(c.at.X, c.at.Y)); got != c.want {
+			t.Errorf("frame %d at %v: got %v, want %v", c.frame, c.at, got, c.want)
+		}
+		if f.Delay != c.delay {
+			t.Errorf("frame %d: got delay %s, want %s", c.frame, f.Delay, c.delay)
+		}
+	}
+}
+
+func TestDecodeAllStill(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 3, 2))
+	img.Pix[4] = 200
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	anim, err := DecodeAll(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anim.Frames) != 1 {
+		t.Fatalf("got %d frames, want 1", len(anim.Frames))
+	}
+	if got := anim.Frames[0].Image.At(1, 1); got != (color.Gray{Y: 200}) {
+		t.Errorf("got %v, want the pixel as it was", got)
+	}
+}
+
+func TestDecodeAllCorrupt(t *testing.T) {
+	data := encodeAPNG(t, []testFrame{{rect: image.Rect(0, 0, 2, 2)}})
+	data[len(data)-20] ^= 0xff
+	if _, err := DecodeAll(bytes.NewReader(data)); err == nil {
+		t.Error("a corrupt chunk decoded without error")
+	}
+}
+
+func TestDecodeAllTooLarge(t *testing.T) {
+	header := func(w, h uint32) []byte {
+		ihdr := make([]byte, 13)
+		binary.BigEndian.PutUint32(ihdr, w)
+		binary.BigEndian.PutUint32(ihdr[4:], h)
+		ihdr[8], ihdr[9] = 8, 6
+		return append([]byte(Signature), chunk("IHDR", ihdr)...)
+	}
+	// a chunk claiming to be 2GB long with nothing after it
+	lying := append(header(2, 2), 0x7f, 0xff, 0xff, 0xff, 'I', 'D', 'A', 'T')
+
+	cases := map[string][]byte{
+		"huge image":  append(header(100000, 100000), chunk("IEND", nil)...),
+		"empty image": append(header(0, 4), chunk("IEND", nil)...),
+		"lying chunk": lying,
+		"too many frames": encodeAPNG(t, func() (frames []testFrame) {
+			// every frame is kept the size of the first, however small it is
+			frames = []testFrame{{rect: image.Rect(0, 0, 4096, 4096)}}
+			for i := 0; i < maxAnimationPixels/(4096*4096); i++ {
+				frames = append(frames, testFrame{rect: image.Rect(0, 0, 1, 1)})
+			}
+			return frames
+		}()),
+	}
+	for name, data := range cases {
+		if _, err := DecodeAll(bytes.NewReader(data)); err == nil {
+			t.Errorf("%s: decoded without error", name)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package colour
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestParseHex(t *testing.T) {
+	cases := []struct {
+		in   string
+		want color.RGBA
+		err  bool
+	}{
+		{"#ff8000", color.RGBA{R: 255, G: 128, A: 255}, false},
+		{"0a0b0c", color.RGBA{R: 10, G: 11, B: 12, A: 255}, false},
+		{"#f80", color.RGBA{R: 255, G: 136, A: 255}, false},
+		{"#ff80", color.RGBA{}, true},
+		{"#gg0000", color.RGBA{}, true},
+		{"", color.RGBA{}, true},
+	}
+	for _, c := range cases {
+		got, err := ParseHex(c.in)
+		if got != c.want || (err != nil) != c.err {
+			t.Errorf("%q: got %v, %v, want %v", c.in, got, err, c.want)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package colour
+
+import (
+	"image/color"
+	"math"
+)
+
+// linearLUT caches the sRGB transfer function for every 8 bit value
+var linearLUT = func() (lut [256]float64) {
+	for i := range lut {
+		lut[i] = ToLinear(float64(i) / 255)
+	}
+	return lut
+}()
+
+// ToLinear decodes a gamma encoded sRGB channel in [0, 1] to linear light.
+func ToLinear(c float64) float64 {
+	if c <= 0.04045 {
+		return c / 12.92
+	}
+	return math.Pow((c+0.055)/1.055, 2.4)
+}
+
+// ToSRGB encodes a linear light channel in [0, 1] with the sRGB transfer function.
+func ToSRGB(c float64) float64 {
+	if c <= 0.0031308 {
+		return c * 12.92
+	}
+	return 1.055*math.Pow(c, 1/2.4) - 0.055
+}
+
+// Lab is a colour in the OKLab perceptual colour space, where euclidean
+// distance is a decent approximation of how different two colours look.
+type Lab struct{ L, A, B float64 }
+
+// OKLab converts an 8 bit sRGB colour to OKLab.
+func OKLab(c color.RGBA) Lab {
+	r, g, b := linearLUT[c.R], linearLUT[c.G], linearLUT[c.B]
+
+	l := math.Cbrt(0.4122214708*r + 0.5363325363*g + 0.0514459929*b)
+	m := math.Cbrt(0.2119034982*r + 0.6806995451*g + 0.1073969566*b)
+	s := math.Cbrt(0.0883024619*r + 0.2817188376*g + 0.6299787005*b)
+
+	return Lab{
+		L: 0.2104542553*l + 0.7936177850*m - 0.0040720468*s,
+		A: 1.9779984951*l - 2.4285922050*m + 0.4505937099*s,
+		B: 0.0259040371*l + 0.7827717662*m - 0.8086757660*s,
+	}
+}
+
+// Distance returns the squared euclidean distance between two OKLab colours.
+func (c Lab) Distance(o Lab) float64 {
+	dl, da, db := c.L-o.L, c.A-o.A, c.B-o.B
+	return dl*dl + da*da + db*db
+}
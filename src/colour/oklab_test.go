@@ -0,0 +1,99 @@
+package colour
+
+import (
+	"image/color"
+	"math"
+	"testing"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-3
+}
+
+func TestOKLab(t *testing.T) {
+	cases := []struct {
+		name string
+		c    color.RGBA
+		want Lab
+	}{
+		{"black", color.RGBA{A: 255}, Lab{}},
+		{"white", color.RGBA{R: 255, G: 255, B: 255, A: 255}, Lab{L: 1}},
+		{"grey", color.RGBA{R: 119, G: 119, B: 119, A: 255}, Lab{L: 0.5693}},
+		{"red", color.RGBA{R: 255, A: 255}, Lab{L: 0.6280, A: 0.2249, B: 0.1258}},
+		{"blue", color.RGBA{B: 255, A: 255}, Lab{L: 0.4520, A: -0.0325, B: -0.3115}},
+	}
+	for _, c := range cases {
+		if got := OKLab(c.c); !near(got.L, c.want.L) || !near(got.A, c.want.A) || !near(got.B, c.want.B) {
+			t.Errorf("%s: got %+v, want %+v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestTransferRoundTrip(t *testing.T) {
+	for _, v := range []float64{0, 0.002, 0.04, 0.2, 0.5, 1} {
+		if got := ToSRGB(ToLinear(v)); !near(got, v) {
+			t.Errorf("%v came back as %v", v, got)
+		}
+	}
+}
+
+func TestOKLabRoundTrip(t *testing.T) {
+	for _, c := range []color.RGBA{
+		{A: 255},
+		{R: 255, G: 255, B: 255, A: 255},
+		{R: 255, A: 255},
+		{G: 255, A: 255},
+		{B: 255, A: 255},
+		{R: 12, G: 200, B: 99, A: 255},
+		{R: 128, G: 64, B: 32, A: 255},
+		{R: 1, G: 2, B: 3, A: 255},
+	} {
+		if got := OKLab(c).RGBA(); got != c {
+			t.Errorf("%v came back as %v", c, got)
+		}
+		if got := OKLab(c).LCh().Lab().RGBA(); got != c {
+			t.Errorf("%v came back from OKLCH as %v", c, got)
+		}
+	}
+}
+
+func TestLCh(t *testing.T) {
+	cases := []struct {
+		name string
+		c    Lab
+		want LCh
+	}{
+		{"grey", Lab{L: 0.5}, LCh{L: 0.5}},
+		{"along a", Lab{L: 0.6, A: 0.1}, LCh{L: 0.6, C: 0.1}},
+		{"along b", Lab{L: 0.6, B: 0.1}, LCh{L: 0.6, C: 0.1, H: math.Pi / 2}},
+		{"against a", Lab{L: 0.6, A: -0.1}, LCh{L: 0.6, C: 0.1, H: math.Pi}},
+		{"diagonal", Lab{L: 0.7, A: 0.03, B: -0.04}, LCh{L: 0.7, C: 0.05, H: math.Atan2(-0.04, 0.03)}},
+	}
+	for _, c := range cases {
+		got := c.c.LCh()
+		if !near(got.L, c.want.L) || !near(got.C, c.want.C) || !near(got.H, c.want.H) {
+			t.Errorf("%s: got %+v, want %+v", c.name, got, c.want)
+		}
+		if back := got.Lab(); !near(back.L, c.c.L) || !near(back.A, c.c.A) || !near(back.B, c.c.B) {
+			t.Errorf("%s: came back as %+v", c.name, back)
+		}
+	}
+}
+
+func TestOutOfGamutClips(t *testing.T) {
+	cases := []struct {
+		name string
+		c    Lab
+		want color.RGBA
+	}{
+		{"too light", Lab{L: 1.5}, color.RGBA{R: 255, G: 255, B: 255, A: 255}},
+		{"too dark", Lab{L: -0.5}, color.RGBA{A: 255}},
+		// more chroma than sRGB red can show stays red
+		{"too red", Lab{L: 0.628, A: 0.4, B: 0.2}, color.RGBA{R: 255, A: 255}},
+	}
+	for _, c := range cases {
+		if got := c.c.RGBA(); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package colour
+
+import (
+	"image/color"
+	"math"
+)
+
+// RGBA converts an OKLab colour back to 8 bit sRGB. Colours outside of the sRGB gamut
+// are clipped channel by channel.
+func (c Lab) RGBA() color.RGBA {
+	l := c.L + 0.3963377774*c.A + 0.2158037573*c.B
+	m := c.L - 0.1055613458*c.A - 0.0638541728*c.B
+	s := c.L - 0.0894841775*c.A - 1.2914855480*c.B
+	l, m, s = l*l*l, m*m*m, s*s*s
+
+	channel := func(v float64) uint8 {
+		return uint8(math.Max(0, math.Min(1, ToSRGB(math.Max(0, v))))*255 + 0.5)
+	}
+	return color.RGBA{
+		R: channel(4.0767416621*l - 3.3077115913*m + 0.2309699292*s),
+		G: channel(-1.2684380046*l + 2.6097574011*m - 0.3413193965*s),
+		B: channel(-0.0041960863*l - 0.7034186147*m + 1.7076147010*s),
+		A: 255,
+	}
+}
+
+// LCh is an OKLab colour in polar form, OKLCH: lightness, chroma and hue in radians.
+// Changing the hue or chroma leaves how light the colour looks alone.
+type LCh struct{ L, C, H float64 }
+
+// LCh converts to polar form.
+func (c Lab) LCh() LCh {
+	return LCh{L: c.L, C: math.Hypot(c.A, c.B), H: math.Atan2(c.B, c.A)}
+}
+
+// Lab converts back from polar form.
+func (c LCh) Lab() Lab {
+	return Lab{L: c.L, A: c.C * math.Cos(c.H), B: c.C * math.Sin(c.H)}
+}
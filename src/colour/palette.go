@@ -0,0 +1,127 @@
+package colour
+
+import (
+	"image/color"
+	"math"
+	"sync"
+)
+
+// cacheBits is the precision per channel of the nearest colour cache.
+const cacheBits = 5
+
+// Palette is a fixed set of terminal colours. The code of Colours[i] is Offset+i,
+// which is the number the terminal expects in the escape sequence.
+type Palette struct {
+	Colours []color.RGBA
+	Offset  int
+
+	lab   []Lab
+	exact map[color.RGBA]uint8
+	cache [1 << (3 * cacheBits)]uint8
+	once  sync.Once
+}
+
+// NewPalette makes a Palette from colours, the first of which has the code offset.
+func NewPalette(offset int, colours ...color.RGBA) *Palette {
+	return &Palette{Colours: colours, Offset: offset}
+}
+
+// Nearest returns the code of the palette entry that looks most like c,
+// measured in OKLab. Colours are bucketed so repeat lookups are cheap.
+func (p *Palette) Nearest(c color.RGBA) uint8 {
+	p.once.Do(p.fillCache)
+
+	// colours that are already in the palette map straight back to their entry
+	if code, ok := p.exact[c]; ok {
+		return code
+	}
+	return p.cache[bucketOf(c)]
+}
+
+// Colour returns the colour the palette shows for code.
+func (p *Palette) Colour(code uint8) color.RGBA {
+	return p.Colours[int(code)-p.Offset]
+}
+
+// bucketOf drops the low bits of each channel to index the cache
+func bucketOf(c color.RGBA) int {
+	const shift = 8 - cacheBits
+	return int(c.R>>shift)<<(2*cacheBits) | int(c.G>>shift)<<cacheBits | int(c.B>>shift)
+}
+
+// fillCache precomputes the nearest entry for the centre of every bucket
+func (p *Palette) fillCache() {
+	p.lab = make([]Lab, len(p.Colours))
+	p.exact = make(map[color.RGBA]uint8, len(p.Colours))
+	for i, c := range p.Colours {
+		p.lab[i] = OKLab(c)
+		p.exact[c] = uint8(p.Offset + i)
+	}
+
+	const shift, half = 8 - cacheBits, 1 << (7 - cacheBits)
+	for i := range p.cache {
+		centre := color.RGBA{
+			R: uint8(i>>(2*cacheBits))<<shift | half,
+			G: uint8(i>>cacheBits&(1<<cacheBits-1))<<shift | half,
+			B: uint8(i&(1<<cacheBits-1))<<shift | half,
+		}
+		p.cache[i] = uint8(p.Offset + p.search(OKLab(centre)))
+	}
+}
+
+// search is the linear nearest neighbour search behind the cache
+func (p *Palette) search(c Lab) (best int) {
+	bestDist := c.Distance(p.lab[0])
+	for i, entry := range p.lab[1:] {
+		if d := c.Distance(entry); d < bestDist {
+			best, bestDist = i+1, d
+		}
+	}
+	return best
+}
+
+// ANSI16 is the basic 16 colour palette with xterm's default values.
+// Themes are free to redefine these, so results may vary.
+var ANSI16 = NewPalette(0,
+	color.RGBA{0, 0, 0, 255},
+	color.RGBA{205, 0, 0, 255},
+	color.RGBA{0, 205, 0, 255},
+	color.RGBA{205, 205, 0, 255},
+	color.RGBA{0, 0, 238, 255},
+	color.RGBA{205, 0, 205, 255},
+	color.RGBA{0, 205, 205, 255},
+	color.RGBA{229, 229, 229, 255},
+	color.RGBA{127, 127, 127, 255},
+	color.RGBA{255, 0, 0, 255},
+	color.RGBA{0, 255, 0, 255},
+	color.RGBA{255, 255, 0, 255},
+	color.RGBA{92, 92, 255, 255},
+	color.RGBA{255, 0, 255, 255},
+	color.RGBA{0, 255, 255, 255},
+	color.RGBA{255, 255, 255, 255},
+)
+
+// Xterm256 is the 6x6x6 colour cube and 24 step grey ramp of the xterm 256 colour palette.
+// The first 16 entries are left out as they're the themeable ANSI16 colours.
+var Xterm256 = func() *Palette {
+	levels := [6]uint8{0, 95, 135, 175, 215, 255}
+	colours := []color.RGBA{}
+	for _, r := range levels {
+		for _, g := range levels {
+			for _, b := range levels {
+				colours = append(colours, color.RGBA{r, g, b, 255})
+			}
+		}
+	}
+	for i := 0; i < 24; i++ {
+		grey := uint8(8 + 10*i)
+		colours = append(colours, color.RGBA{grey, grey, grey, 255})
+	}
+	return NewPalette(16, colours...)
+}()
+
+// Step estimates the distance between neighbouring entries along a channel,
+// treating the palette as if it were an evenly spaced colour cube.
+func (p *Palette) Step() float64 {
+	return 255 / (math.Cbrt(float64(len(p.Colours))) - 1)
+}
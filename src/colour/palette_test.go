@@ -0,0 +1,137 @@
+package colour
+
+import (
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestXterm256Layout(t *testing.T) {
+	if len(Xterm256.Colours) != 240 || Xterm256.Offset != 16 {
+		t.Fatalf("got %d colours from %d, want 240 from 16", len(Xterm256.Colours), Xterm256.Offset)
+	}
+	cases := []struct {
+		name string
+		code uint8
+		want color.RGBA
+	}{
+		{"cube black", 16, color.RGBA{A: 255}},
+		{"cube blue", 21, color.RGBA{B: 255, A: 255}},
+		{"cube first step", 16 + 36 + 6 + 1, color.RGBA{R: 95, G: 95, B: 95, A: 255}},
+		{"cube mixed", 16 + 36*4 + 6*2 + 3, color.RGBA{R: 215, G: 135, B: 175, A: 255}},
+		{"cube red", 196, color.RGBA{R: 255, A: 255}},
+		{"cube white", 231, color.RGBA{R: 255, G: 255, B: 255, A: 255}},
+		{"darkest grey", 232, color.RGBA{R: 8, G: 8, B: 8, A: 255}},
+		{"mid grey", 244, color.RGBA{R: 128, G: 128, B: 128, A: 255}},
+		{"lightest grey", 255, color.RGBA{R: 238, G: 238, B: 238, A: 255}},
+	}
+	for _, c := range cases {
+		if got := Xterm256.Colour(c.code); got != c.want {
+			t.Errorf("%s: %d is %v, want %v", c.name, c.code, got, c.want)
+		}
+	}
+}
+
+func TestANSI16Codes(t *testing.T) {
+	cases := []struct {
+		name string
+		code uint8
+		want color.RGBA
+	}{
+		{"black", 0, color.RGBA{A: 255}},
+		{"red", 1, color.RGBA{R: 205, A: 255}},
+		{"white", 7, color.RGBA{R: 229, G: 229, B: 229, A: 255}},
+		{"bright black", 8, color.RGBA{R: 127, G: 127, B: 127, A: 255}},
+		{"bright blue", 12, color.RGBA{R: 92, G: 92, B: 255, A: 255}},
+		{"bright white", 15, color.RGBA{R: 255, G: 255, B: 255, A: 255}},
+	}
+	for _, c := range cases {
+		if got := ANSI16.Colour(c.code); got != c.want {
+			t.Errorf("%s: %d is %v, want %v", c.name, c.code, got, c.want)
+		}
+	}
+}
+
+func TestNearest(t *testing.T) {
+	cases := []struct {
+		name    string
+		palette *Palette
+		c       color.RGBA
+		want    uint8
+	}{
+		{"dim red", ANSI16, color.RGBA{R: 200, G: 1, B: 2, A: 255}, 1},
+		{"bright red", ANSI16, color.RGBA{R: 250, G: 10, B: 10, A: 255}, 9},
+		{"dark grey", ANSI16, color.RGBA{R: 30, G: 30, B: 30, A: 255}, 0},
+		{"navy", ANSI16, color.RGBA{B: 100, A: 255}, 4},
+		{"red in the cube", Xterm256, color.RGBA{R: 200, G: 1, B: 2, A: 255}, 160},
+		{"navy in the cube", Xterm256, color.RGBA{B: 100, A: 255}, 17},
+		{"grey on the ramp", Xterm256, color.RGBA{R: 100, G: 100, B: 100, A: 255}, 241},
+		// black's bucket is closer to the ramp's darkest grey, but black itself is in the cube
+		{"black", Xterm256, color.RGBA{A: 255}, 16},
+		{"ramp grey between cube greys", Xterm256, color.RGBA{R: 128, G: 128, B: 128, A: 255}, 244},
+	}
+	for _, c := range cases {
+		if got := c.palette.Nearest(c.c); got != c.want {
+			t.Errorf("%s: %v came out as %d, want %d", c.name, c.c, got, c.want)
+		}
+	}
+
+	// every entry maps back to itself, whatever the rest of its bucket is nearest to
+	for _, p := range []*Palette{ANSI16, Xterm256} {
+		for i, c := range p.Colours {
+			if got := p.Nearest(c); int(got) != p.Offset+i {
+				t.Errorf("%v came out as %d, want %d", c, got, p.Offset+i)
+			}
+		}
+	}
+}
+
+func TestNearestBuckets(t *testing.T) {
+	cases := []struct {
+		name string
+		a, b color.RGBA
+		same bool
+	}{
+		// the low 3 bits of each channel are dropped
+		{"same bucket", color.RGBA{R: 200, G: 1, B: 2, A: 255}, color.RGBA{R: 207, G: 6, B: 7, A: 255}, true},
+		{"alpha is ignored", color.RGBA{R: 200, G: 1, B: 2, A: 255}, color.RGBA{R: 200, G: 1, B: 2}, true},
+		{"next bucket", color.RGBA{R: 207, A: 255}, color.RGBA{R: 208, A: 255}, false},
+		{"green bucket", color.RGBA{G: 7, A: 255}, color.RGBA{G: 8, A: 255}, false},
+		{"blue bucket", color.RGBA{B: 7, A: 255}, color.RGBA{B: 8, A: 255}, false},
+	}
+	for _, c := range cases {
+		if got := bucketOf(c.a) == bucketOf(c.b); got != c.same {
+			t.Errorf("%s: %v and %v sharing a bucket is %v", c.name, c.a, c.b, got)
+		}
+	}
+
+	// each bucket holds the entry nearest its centre
+	p := NewPalette(100, color.RGBA{A: 255}, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+	for _, c := range []color.RGBA{{R: 60, G: 60, B: 60, A: 255}, {R: 200, G: 200, B: 200, A: 255}} {
+		centre := color.RGBA{R: c.R&^7 | 4, G: c.G&^7 | 4, B: c.B&^7 | 4, A: 255}
+		if got, want := p.Nearest(c), uint8(100+p.search(OKLab(centre))); got != want {
+			t.Errorf("%v came out as %d, want %d", c, got, want)
+		}
+	}
+	if p.Nearest(color.RGBA{R: 60, G: 60, B: 60, A: 255}) != 100 || p.Nearest(color.RGBA{R: 200, G: 200, B: 200, A: 255}) != 101 {
+		t.Error("greys weren't split between black and white")
+	}
+}
+
+func TestStep(t *testing.T) {
+	cases := []struct {
+		name    string
+		palette *Palette
+		want    float64
+	}{
+		// 240 colours are a cube a little over 6 a side
+		{"xterm", Xterm256, 48.9},
+		{"ansi", ANSI16, 167.8},
+		{"cube", NewPalette(0, make([]color.RGBA, 27)...), 127.5},
+	}
+	for _, c := range cases {
+		if got := c.palette.Step(); math.Abs(got-c.want) > 0.1 {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package dither
+
+// weight is a share of the quantization error given to the pixel at dx, dy
+type weight struct {
+	dx, dy int
+	w      float64
+}
+
+// Diffusion is an error diffusion method, it walks the plane pushing the error
+// of every pixel onto the neighbours that have yet to be quantized.
+type Diffusion []weight
+
+// FloydSteinberg diffuses all of the error over the four forward neighbours.
+var FloydSteinberg = Diffusion{
+	{1, 0, 7.0 / 16}, {-1, 1, 3.0 / 16}, {0, 1, 5.0 / 16}, {1, 1, 1.0 / 16},
+}
+
+// Atkinson diffuses only three quarters of the error, which keeps more contrast
+// at the cost of blowing out the highlights and shadows.
+var Atkinson = Diffusion{
+	{1, 0, 1.0 / 8}, {2, 0, 1.0 / 8}, {-1, 1, 1.0 / 8}, {0, 1, 1.0 / 8}, {1, 1, 1.0 / 8}, {0, 2, 1.0 / 8},
+}
+
+func (d Diffusion) Dither(p *Plane, _ float64, q Quantizer) {
+	orig := make([]float64, p.Channels)
+	for y := 0; y < p.H; y++ {
+		for x := 0; x < p.W; x++ {
+			px := p.At(x, y)
+			copy(orig, px)
+			q(px)
+
+			for _, k := range d {
+				nx, ny := x+k.dx, y+k.dy
+				if nx < 0 || nx >= p.W || ny >= p.H {
+					continue
+				}
+				neighbour := p.At(nx, ny)
+				for c := range neighbour {
+					neighbour[c] += (orig[c] - px[c]) * k.w
+				}
+			}
+		}
+	}
+}
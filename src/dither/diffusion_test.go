@@ -0,0 +1,95 @@
+package dither
+
+import "testing"
+
+func TestDiffusionKeepsMidGrey(t *testing.T) {
+	cases := []struct {
+		name   string
+		method Diffusion
+	}{
+		{"floyd-steinberg", FloydSteinberg},
+		{"atkinson", Atkinson},
+	}
+	for _, c := range cases {
+		p := filled(32, 32, 0.5)
+		c.method.Dither(p, 1, binary)
+
+		sum := 0.0
+		for _, v := range p.Pix {
+			sum += v
+		}
+		if mean := sum / float64(len(p.Pix)); !near(mean, 0.5) {
+			t.Errorf("%s: mid grey came out with a mean of %v", c.name, mean)
+		}
+		// each row is a mix, not a run of black or white
+		for y := 0; y < p.H; y++ {
+			row := 0.0
+			for x := 0; x < p.W; x++ {
+				row += p.At(x, y)[0]
+			}
+			if row < 8 || row > 24 {
+				t.Errorf("%s: row %d has %v white pixels of %d", c.name, y, row, p.W)
+				break
+			}
+		}
+	}
+}
+
+func TestDiffusionWeights(t *testing.T) {
+	cases := []struct {
+		name   string
+		method Diffusion
+		total  float64
+	}{
+		{"floyd-steinberg", FloydSteinberg, 1},
+		// atkinson drops a quarter of the error
+		{"atkinson", Atkinson, 0.75},
+	}
+	for _, c := range cases {
+		sum := 0.0
+		for _, k := range c.method {
+			// only pixels yet to be quantized take any error
+			if k.dy < 0 || k.dy == 0 && k.dx <= 0 {
+				t.Errorf("%s: error pushed back to %d, %d", c.name, k.dx, k.dy)
+			}
+			sum += k.w
+		}
+		if !near(sum, c.total) {
+			t.Errorf("%s: weights add up to %v, want %v", c.name, sum, c.total)
+		}
+	}
+}
+
+func TestDiffusionEdges(t *testing.T) {
+	// worked by hand: error off the right and bottom edges is dropped, and the error pushed
+	// down and to the left of the first column doesn't wrap onto the row above's last pixel
+	p := filled(2, 2, 0.5)
+	seen := []float64{}
+	FloydSteinberg.Dither(p, 1, func(px []float64) {
+		seen = append(seen, px[0])
+		binary(px)
+	})
+	want := []float64{0.5, 0.28125, 0.396484375, 0.7301025390625}
+	for i := range want {
+		if !near(seen[i], want[i]) {
+			t.Fatalf("quantizer saw %v, want %v", seen, want)
+		}
+	}
+	if got := p.Pix; got[0] != 1 || got[1] != 0 || got[2] != 0 || got[3] != 1 {
+		t.Errorf("got %v, want a checkerboard", got)
+	}
+
+	// a single column only ever passes its error straight down
+	p = filled(1, 3, 0.5)
+	seen = seen[:0]
+	Atkinson.Dither(p, 1, func(px []float64) {
+		seen = append(seen, px[0])
+		binary(px)
+	})
+	want = []float64{0.5, 0.4375, 0.4921875}
+	for i := range want {
+		if !near(seen[i], want[i]) {
+			t.Fatalf("quantizer saw %v, want %v", seen, want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package dither
+
+// Plane is a W x H grid of pixels with Channels samples each, stored as floats
+// so that the error pushed onto neighbouring pixels isn't lost to rounding.
+type Plane struct {
+	W, H, Channels int
+	Pix            []float64
+}
+
+// NewPlane allocates a zeroed Plane.
+func NewPlane(w, h, channels int) *Plane {
+	return &Plane{W: w, H: h, Channels: channels, Pix: make([]float64, w*h*channels)}
+}
+
+// At returns the samples of the pixel at x, y. The slice aliases the plane.
+func (p *Plane) At(x, y int) []float64 {
+	i := (y*p.W + x) * p.Channels
+	return p.Pix[i : i+p.Channels]
+}
+
+// Quantizer snaps a pixel, in place, to the nearest value that can be displayed.
+type Quantizer func(px []float64)
+
+// Method is a dithering algorithm. Dither quantizes every pixel of the plane with q,
+// calling it exactly once per pixel in raster order. Step is the typical distance between
+// two neighbouring quantized values, which ordered methods use to size their noise.
+type Method interface {
+	Dither(p *Plane, step float64, q Quantizer)
+}
+
+// None quantizes each pixel on its own, i.e. no dithering at all.
+type None struct{}
+
+func (None) Dither(p *Plane, _ float64, q Quantizer) {
+	for i := 0; i < len(p.Pix); i += p.Channels {
+		q(p.Pix[i : i+p.Channels])
+	}
+}
+
+// Methods is the mapping of name to dithering algorithm.
+var Methods = map[string]Method{
+	"none":            None{},
+	"floyd-steinberg": FloydSteinberg,
+	"atkinson":        Atkinson,
+	"bayer2":          Bayer(2),
+	"bayer4":          Bayer(4),
+	"bayer8":          Bayer(8),
+}
@@ -0,0 +1,91 @@
+package dither
+
+import (
+	"math"
+	"testing"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+// filled returns a w by h plane of one channel set to v
+func filled(w, h int, v float64) *Plane {
+	p := NewPlane(w, h, 1)
+	for i := range p.Pix {
+		p.Pix[i] = v
+	}
+	return p
+}
+
+// binary snaps a one channel pixel to black or white
+func binary(px []float64) {
+	if px[0] >= 0.5 {
+		px[0] = 1
+	} else {
+		px[0] = 0
+	}
+}
+
+func TestPlane(t *testing.T) {
+	p := NewPlane(3, 2, 2)
+	if len(p.Pix) != 12 {
+		t.Fatalf("got %d samples, want 12", len(p.Pix))
+	}
+	for _, v := range p.Pix {
+		if v != 0 {
+			t.Fatalf("a new plane holds %v", p.Pix)
+		}
+	}
+
+	cases := []struct {
+		x, y, i int
+	}{
+		{0, 0, 0},
+		{1, 0, 2},
+		{2, 0, 4},
+		{0, 1, 6},
+		{2, 1, 10},
+	}
+	for _, c := range cases {
+		px := p.At(c.x, c.y)
+		if len(px) != 2 {
+			t.Fatalf("%d, %d: got %d channels, want 2", c.x, c.y, len(px))
+		}
+		// writes through the pixel land in the plane
+		px[0], px[1] = float64(c.i), float64(c.i+1)
+		if p.Pix[c.i] != float64(c.i) || p.Pix[c.i+1] != float64(c.i+1) {
+			t.Errorf("%d, %d: wrote to %v, want samples %d and %d", c.x, c.y, p.Pix, c.i, c.i+1)
+		}
+	}
+}
+
+// every method hands each pixel to the quantizer once, in raster order
+func TestMethodsQuantizeInRasterOrder(t *testing.T) {
+	for name, method := range Methods {
+		for _, size := range [][2]int{{5, 3}, {1, 4}, {4, 1}, {1, 1}} {
+			w, h := size[0], size[1]
+			p := NewPlane(w, h, 1)
+			next := 0
+			method.Dither(p, 0.5, func(px []float64) {
+				if want := p.At(next%w, next/w); &px[0] != &want[0] {
+					t.Errorf("%s %dx%d: call %d wasn't given pixel %d, %d", name, w, h, next, next%w, next/w)
+				}
+				next++
+			})
+			if next != w*h {
+				t.Errorf("%s %dx%d: quantized %d pixels, want %d", name, w, h, next, w*h)
+			}
+		}
+	}
+}
+
+func TestNone(t *testing.T) {
+	p := filled(4, 4, 0.49)
+	None{}.Dither(p, 1, binary)
+	for _, v := range p.Pix {
+		if v != 0 {
+			t.Fatalf("got %v, want all black", p.Pix)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package dither
+
+// Ordered is an ordered dithering method. Each pixel is nudged by a fixed threshold from
+// the matrix before quantizing, so the result of a pixel never depends on its neighbours.
+// That makes it the stable choice for animation, where error diffusion shimmers.
+type Ordered struct {
+	n         int
+	threshold []float64
+}
+
+// Bayer returns the ordered method using the n x n Bayer matrix, n must be a power of two.
+func Bayer(n int) Ordered {
+	// grow the index matrix from 1x1 by the usual recursive construction
+	m := []int{0}
+	for size := 1; size < n; size *= 2 {
+		next := make([]int, 4*size*size)
+		for y := 0; y < size; y++ {
+			for x := 0; x < size; x++ {
+				v := 4 * m[y*size+x]
+				next[y*2*size+x] = v
+				next[y*2*size+x+size] = v + 2
+				next[(y+size)*2*size+x] = v + 3
+				next[(y+size)*2*size+x+size] = v + 1
+			}
+		}
+		m = next
+	}
+
+	// centre the thresholds on zero so the average brightness is unchanged
+	threshold := make([]float64, len(m))
+	for i, v := range m {
+		threshold[i] = (float64(v)+0.5)/float64(len(m)) - 0.5
+	}
+	return Ordered{n: n, threshold: threshold}
+}
+
+func (o Ordered) Dither(p *Plane, step float64, q Quantizer) {
+	for y := 0; y < p.H; y++ {
+		for x := 0; x < p.W; x++ {
+			px := p.At(x, y)
+			bias := o.threshold[(y%o.n)*o.n+x%o.n] * step
+			for c := range px {
+				px[c] += bias
+			}
+			q(px)
+		}
+	}
+}
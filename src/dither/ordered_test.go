@@ -0,0 +1,77 @@
+package dither
+
+import (
+	"math"
+	"testing"
+)
+
+// indices recovers the index matrix from the thresholds
+func indices(o Ordered) []int {
+	m := make([]int, len(o.threshold))
+	for i, th := range o.threshold {
+		m[i] = int(math.Round((th+0.5)*float64(len(o.threshold)) - 0.5))
+	}
+	return m
+}
+
+func TestBayerMatrices(t *testing.T) {
+	for _, n := range []int{2, 4, 8} {
+		o := Bayer(n)
+		if o.n != n || len(o.threshold) != n*n {
+			t.Fatalf("bayer%d: got a %d matrix of %d thresholds", n, o.n, len(o.threshold))
+		}
+
+		seen := make([]bool, n*n)
+		sum := 0.0
+		for i, v := range indices(o) {
+			if v < 0 || v >= n*n || seen[v] {
+				t.Errorf("bayer%d: %v isn't a permutation of 0 to %d", n, indices(o), n*n-1)
+				break
+			}
+			seen[v] = true
+			sum += o.threshold[i]
+		}
+		// centred on zero, so a flat plane keeps its brightness
+		if !near(sum, 0) {
+			t.Errorf("bayer%d: thresholds add up to %v", n, sum)
+		}
+	}
+
+	if got := indices(Bayer(2)); got[0] != 0 || got[1] != 2 || got[2] != 3 || got[3] != 1 {
+		t.Errorf("bayer2: got %v, want [0 2 3 1]", got)
+	}
+	if got := indices(Bayer(4))[:4]; got[0] != 0 || got[1] != 8 || got[2] != 2 || got[3] != 10 {
+		t.Errorf("bayer4: first row %v, want [0 8 2 10]", got)
+	}
+}
+
+func TestBayerThreshold(t *testing.T) {
+	// the bias is the threshold scaled by the step, tiled across the plane
+	p := filled(5, 3, 0.5)
+	Bayer(2).Dither(p, 0.4, func([]float64) {})
+	want := []float64{
+		0.35, 0.55, 0.35, 0.55, 0.35,
+		0.65, 0.45, 0.65, 0.45, 0.65,
+		0.35, 0.55, 0.35, 0.55, 0.35,
+	}
+	for i := range want {
+		if !near(p.Pix[i], want[i]) {
+			t.Fatalf("got %v, want %v", p.Pix, want)
+		}
+	}
+
+	// a grey of k / n² turns exactly k pixels of each tile white
+	for _, n := range []int{2, 4, 8} {
+		for k := 0; k <= n*n; k++ {
+			p := filled(2*n, 2*n, float64(k)/float64(n*n))
+			Bayer(n).Dither(p, 1, binary)
+			white := 0.0
+			for _, v := range p.Pix {
+				white += v
+			}
+			if int(white) != 4*k {
+				t.Errorf("bayer%d: grey %d/%d made %v white pixels, want %d", n, k, n*n, white, 4*k)
+			}
+		}
+	}
+}
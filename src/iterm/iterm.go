@@ -0,0 +1,36 @@
+package iterm
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/png"
+	"io"
+	"strconv"
+)
+
+// Inline images are sent as an operating system command terminated by a bell.
+const (
+	Start = "\u001b]1337;File="
+	End   = "\a"
+)
+
+// Encode writes img as an inline image stretched over cols x rows terminal cells.
+// The pixels are sent as a PNG file, iTerm2 and WezTerm decode it at their end.
+func Encode(w io.Writer, img image.Image, cols, rows int) error {
+	file := &bytes.Buffer{}
+	enc := png.Encoder{CompressionLevel: png.BestSpeed}
+	if err := enc.Encode(file, img); err != nil {
+		return err
+	}
+
+	// photerm has already worked out the aspect ratio, so the terminal is told not to
+	args := "inline=1" +
+		";size=" + strconv.Itoa(file.Len()) +
+		";width=" + strconv.Itoa(cols) +
+		";height=" + strconv.Itoa(rows) +
+		";preserveAspectRatio=0"
+
+	_, err := io.WriteString(w, Start+args+":"+base64.StdEncoding.EncodeToString(file.Bytes())+End)
+	return err
+}
@@ -0,0 +1,216 @@
+package kitty
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"wombatlord/photerm/src/term"
+)
+
+// Graphics commands are application program commands starting with G
+const (
+	Start = "\u001b_G"
+	End   = "\u001b\\"
+)
+
+// chunkSize is the largest base64 payload the protocol allows in one escape sequence
+const chunkSize = 4096
+
+// Format is the encoding of the pixel data sent to the terminal
+type Format int
+
+const (
+	// RGBA sends raw 32 bit pixels, cheap to encode but large.
+	RGBA Format = 32
+	// PNG sends PNG compressed pixels, which is kinder to slow links.
+	PNG Format = 100
+)
+
+// Encoder writes images to an io.Writer using the kitty graphics protocol.
+type Encoder struct {
+	w io.Writer
+
+	Format Format
+	// ImageID and PlacementID identify the image on the terminal. Sending another image with
+	// the same ids replaces the previous one in place, which is how animation is done.
+	// An ImageID of 0 sends the image without ids, so nothing sent later can replace it.
+	ImageID, PlacementID int
+
+	// frames are shown once and thrown away, so favour speed over size
+	png png.Encoder
+}
+
+// NewEncoder returns an Encoder writing PNG payloads to w, with the image id given
+// or 0 for an image that stays put.
+func NewEncoder(w io.Writer, imageID int) *Encoder {
+	return &Encoder{
+		w:           w,
+		Format:      PNG,
+		ImageID:     imageID,
+		PlacementID: 1,
+		png:         png.Encoder{CompressionLevel: png.BestSpeed},
+	}
+}
+
+// Encode transmits img and displays it stretched over cols x rows terminal cells.
+// The cursor is left where it was, at the top left of the image.
+func (e *Encoder) Encode(img *image.RGBA, cols, rows int) error {
+	payload := &bytes.Buffer{}
+	keys := []string{
+		"a=T",
+		"q=2",
+		"f=" + strconv.Itoa(int(e.Format)),
+		"c=" + strconv.Itoa(cols),
+		"r=" + strconv.Itoa(rows),
+		"C=1",
+	}
+	if e.ImageID != 0 {
+		keys = append(keys, "i="+strconv.Itoa(e.ImageID), "p="+strconv.Itoa(e.PlacementID))
+	}
+
+	switch e.Format {
+	case PNG:
+		if err := e.png.Encode(payload, img); err != nil {
+			return err
+		}
+	default:
+		// raw data has no header, so the size goes in the keys
+		bounds := img.Bounds()
+		keys = append(keys, "s="+strconv.Itoa(bounds.Dx()), "v="+strconv.Itoa(bounds.Dy()))
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			i := img.PixOffset(bounds.Min.X, y)
+			payload.Write(img.Pix[i : i+4*bounds.Dx()])
+		}
+	}
+
+	return writeChunked(e.w, strings.Join(keys, ","), payload.Bytes())
+}
+
+// Delete removes the image and all of its placements from the terminal.
+func (e *Encoder) Delete() error {
+	_, err := fmt.Fprintf(e.w, "%sa=d,d=I,q=2,i=%d%s", Start, e.ImageID, End)
+	return err
+}
+
+// writeChunked sends the payload base64 encoded, split up so no escape sequence is over the limit.
+// Only the first chunk carries the keys, the rest just say whether more is coming.
+func writeChunked(w io.Writer, keys string, payload []byte) error {
+	out := bufio.NewWriter(w)
+	encoded := base64.StdEncoding.EncodeToString(payload)
+	for first := true; first || len(encoded) > 0; first = false {
+		chunk := encoded
+		if len(chunk) > chunkSize {
+			chunk = chunk[:chunkSize]
+		}
+		encoded = encoded[len(chunk):]
+
+		more := "0"
+		if len(encoded) > 0 {
+			more = "1"
+		}
+		if first {
+			out.WriteString(Start + keys + ",m=" + more + ";" + chunk + End)
+		} else {
+			out.WriteString(Start + "m=" + more + ";" + chunk + End)
+		}
+	}
+	return out.Flush()
+}
+
+// Response is a reply from the terminal to a graphics command.
+type Response struct {
+	ImageID int
+	OK      bool
+	// Message is OK, or an error code and description such as "ENOENT:file not found"
+	Message string
+}
+
+// ParseResponse parses a single graphics protocol reply, escape sequences included.
+func ParseResponse(reply []byte) (Response, error) {
+	res := Response{}
+	s := string(reply)
+	if !strings.HasPrefix(s, Start) || !strings.HasSuffix(s, End) {
+		return res, fmt.Errorf("kitty: not a graphics response: %q", s)
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, Start), End)
+
+	keys, message, found := strings.Cut(s, ";")
+	if !found {
+		return res, fmt.Errorf("kitty: response has no message: %q", s)
+	}
+	for _, kv := range strings.Split(keys, ",") {
+		k, v, _ := strings.Cut(kv, "=")
+		if k == "i" {
+			id, err := strconv.Atoi(v)
+			if err != nil {
+				return res, fmt.Errorf("kitty: bad image id in response: %q", v)
+			}
+			res.ImageID = id
+		}
+	}
+	res.Message = message
+	res.OK = message == "OK"
+	return res, nil
+}
+
+// FindResponses picks out every graphics reply from what the terminal sent back,
+// which may be mixed in with replies to other queries.
+func FindResponses(reply []byte) (responses []Response) {
+	for {
+		start := bytes.Index(reply, []byte(Start))
+		if start < 0 {
+			return responses
+		}
+		end := bytes.Index(reply[start:], []byte(End))
+		if end < 0 {
+			return responses
+		}
+		end += start + len(End)
+		if res, err := ParseResponse(reply[start:end]); err == nil {
+			responses = append(responses, res)
+		}
+		reply = reply[end:]
+	}
+}
+
+// queryID is the image id used when probing for support, nothing is ever displayed with it
+const queryID = 31
+
+// QueryCommand asks the terminal to check a one pixel image without storing it.
+// Terminals that understand the protocol reply, everything else ignores it.
+var QueryCommand = fmt.Sprintf("%si=%d,s=1,v=1,a=q,t=d,f=24;AAAA%s", Start, queryID, End)
+
+// primaryDA asks for the primary device attributes, which every terminal answers.
+// Sent after the graphics query, its reply means there is no graphics reply coming.
+const primaryDA = "\u001b[c"
+
+// Query asks the terminal on rw whether it supports the graphics protocol.
+func Query(rw io.ReadWriter, timeout time.Duration) (bool, error) {
+	if _, err := io.WriteString(rw, QueryCommand+primaryDA); err != nil {
+		return false, err
+	}
+	reply, err := term.ReadUntil(rw, timeout, func(reply []byte) bool {
+		// the device attributes reply looks like ESC [ ? ... c
+		i := bytes.Index(reply, []byte("\u001b[?"))
+		return i >= 0 && bytes.IndexByte(reply[i:], 'c') >= 0
+	})
+	return Supported(reply), err
+}
+
+// Supported reports whether reply contains a successful answer to QueryCommand.
+func Supported(reply []byte) bool {
+	for _, res := range FindResponses(reply) {
+		if res.ImageID == queryID && res.OK {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,224 @@
+package kitty
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeTerminal answers the queries written to it from a script of query -> reply,
+// like a terminal would, and stays silent about anything it doesn't know.
+type fakeTerminal struct {
+	script  map[string]string
+	replies bytes.Buffer
+}
+
+func (t *fakeTerminal) Write(p []byte) (int, error) {
+	// replies go out in the order the queries came in
+	queries := string(p)
+	for len(queries) > 0 {
+		next, nextAt := "", len(queries)
+		for query := range t.script {
+			if i := strings.Index(queries, query); i >= 0 && i < nextAt {
+				next, nextAt = query, i
+			}
+		}
+		if next == "" {
+			break
+		}
+		t.replies.WriteString(t.script[next])
+		queries = queries[nextAt+len(next):]
+	}
+	return len(p), nil
+}
+
+func (t *fakeTerminal) Read(p []byte) (int, error) {
+	return t.replies.Read(p)
+}
+
+// commands splits what was written into the bodies of the graphics commands
+func commands(t *testing.T, out string) []string {
+	cmds := []string{}
+	for _, cmd := range strings.SplitAfter(out, End) {
+		if cmd == "" {
+			continue
+		}
+		if !strings.HasPrefix(cmd, Start) || !strings.HasSuffix(cmd, End) {
+			t.Fatalf("%q isn't a graphics command", cmd)
+		}
+		cmds = append(cmds, strings.TrimSuffix(strings.TrimPrefix(cmd, Start), End))
+	}
+	return cmds
+}
+
+func TestWriteChunked(t *testing.T) {
+	cases := []struct {
+		name   string
+		size   int
+		chunks int
+	}{
+		{"empty", 0, 1},
+		{"small", 10, 1},
+		// 3072 bytes are exactly 4096 in base64
+		{"one full chunk", 3072, 1},
+		{"just over", 3073, 2},
+		{"several", 3*3072 + 1, 4},
+	}
+	for _, c := range cases {
+		payload := make([]byte, c.size)
+		for i := range payload {
+			payload[i] = byte(i * 7)
+		}
+		out := &bytes.Buffer{}
+		if err := writeChunked(out, "a=T,i=3", payload); err != nil {
+			t.Fatal(err)
+		}
+
+		cmds := commands(t, out.String())
+		if len(cmds) != c.chunks {
+			t.Errorf("%s: got %d chunks, want %d", c.name, len(cmds), c.chunks)
+			continue
+		}
+		encoded := ""
+		for i, cmd := range cmds {
+			keys, chunk, _ := strings.Cut(cmd, ";")
+			// the keys only go on the first chunk, and all but the last say more is coming
+			want := "m=1"
+			if i == len(cmds)-1 {
+				want = "m=0"
+			}
+			if i == 0 {
+				want = "a=T,i=3," + want
+			}
+			if keys != want {
+				t.Errorf("%s: chunk %d has keys %q, want %q", c.name, i, keys, want)
+			}
+			if len(chunk) > chunkSize || i < len(cmds)-1 && len(chunk) != chunkSize {
+				t.Errorf("%s: chunk %d is %d bytes", c.name, i, len(chunk))
+			}
+			encoded += chunk
+		}
+		if decoded, err := base64.StdEncoding.DecodeString(encoded); err != nil || !bytes.Equal(decoded, payload) {
+			t.Errorf("%s: the chunks don't add up to the payload: %v", c.name, err)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	img.Pix = []uint8{1, 2, 3, 4, 5, 6, 7, 8}
+	cases := []struct {
+		name    string
+		format  Format
+		imageID int
+		keys    string
+	}{
+		{"raw", RGBA, 0, "a=T,q=2,f=32,c=4,r=3,C=1,s=2,v=1,m=0"},
+		{"raw with ids", RGBA, 5, "a=T,q=2,f=32,c=4,r=3,C=1,i=5,p=1,s=2,v=1,m=0"},
+		{"png", PNG, 5, "a=T,q=2,f=100,c=4,r=3,C=1,i=5,p=1,m=0"},
+	}
+	for _, c := range cases {
+		out := &bytes.Buffer{}
+		e := NewEncoder(out, c.imageID)
+		e.Format = c.format
+		if err := e.Encode(img, 4, 3); err != nil {
+			t.Fatal(err)
+		}
+		keys, payload, _ := strings.Cut(commands(t, out.String())[0], ";")
+		if keys != c.keys {
+			t.Errorf("%s: got keys %q, want %q", c.name, keys, c.keys)
+		}
+		if c.format == RGBA && payload != base64.StdEncoding.EncodeToString(img.Pix) {
+			t.Errorf("%s: got payload %q", c.name, payload)
+		}
+	}
+
+	out := &bytes.Buffer{}
+	if err := NewEncoder(out, 5).Delete(); err != nil {
+		t.Fatal(err)
+	}
+	if want := Start + "a=d,d=I,q=2,i=5" + End; out.String() != want {
+		t.Errorf("delete sent %q, want %q", out, want)
+	}
+}
+
+func TestParseResponse(t *testing.T) {
+	cases := []struct {
+		name  string
+		reply string
+		want  Response
+		err   bool
+	}{
+		{"ok", Start + "i=31;OK" + End, Response{ImageID: 31, OK: true, Message: "OK"}, false},
+		{"error", Start + "i=7;ENOENT:file not found" + End, Response{ImageID: 7, Message: "ENOENT:file not found"}, false},
+		{"more keys", Start + "i=2,p=1;OK" + End, Response{ImageID: 2, OK: true, Message: "OK"}, false},
+		{"no id", Start + ";OK" + End, Response{OK: true, Message: "OK"}, false},
+		{"bad id", Start + "i=x;OK" + End, Response{}, true},
+		{"no message", Start + "i=31" + End, Response{}, true},
+		{"unterminated", Start + "i=31;OK", Response{}, true},
+		{"something else", "\u001b[?62;c", Response{}, true},
+	}
+	for _, c := range cases {
+		got, err := ParseResponse([]byte(c.reply))
+		if (err != nil) != c.err || !c.err && got != c.want {
+			t.Errorf("%s: got %+v, %v, want %+v", c.name, got, err, c.want)
+		}
+	}
+}
+
+func TestFindResponses(t *testing.T) {
+	cases := []struct {
+		name  string
+		reply string
+		want  []Response
+	}{
+		{"nothing", "", nil},
+		{"other replies", "\u001b[?62;c\u001bP1+r5463\u001b\\", nil},
+		{"one", Start + "i=31;OK" + End, []Response{{ImageID: 31, OK: true, Message: "OK"}}},
+		{"mixed in", "\u001b[?62;c" + Start + "i=1;OK" + End + "\u001b]11;rgb:0/0/0\u001b\\" + Start + "i=2;EINVAL:bad" + End,
+			[]Response{{ImageID: 1, OK: true, Message: "OK"}, {ImageID: 2, Message: "EINVAL:bad"}}},
+		{"unparsable skipped", Start + "i=x;OK" + End + Start + "i=3;OK" + End, []Response{{ImageID: 3, OK: true, Message: "OK"}}},
+		{"cut short", Start + "i=1;OK" + End + Start + "i=2;O", []Response{{ImageID: 1, OK: true, Message: "OK"}}},
+	}
+	for _, c := range cases {
+		got := FindResponses([]byte(c.reply))
+		if len(got) != len(c.want) {
+			t.Errorf("%s: got %+v, want %+v", c.name, got, c.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("%s: got %+v, want %+v", c.name, got, c.want)
+				break
+			}
+		}
+	}
+}
+
+func TestQuery(t *testing.T) {
+	const da1 = "\u001b[c"
+	cases := []struct {
+		name   string
+		script map[string]string
+		want   bool
+	}{
+		{"kitty", map[string]string{QueryCommand: Start + "i=31;OK" + End, da1: "\u001b[?62;c"}, true},
+		{"refused", map[string]string{QueryCommand: Start + "i=31;EINVAL:bad format" + End, da1: "\u001b[?62;c"}, false},
+		{"another image", map[string]string{QueryCommand: Start + "i=30;OK" + End, da1: "\u001b[?62;c"}, false},
+		{"ignored", map[string]string{da1: "\u001b[?1;2;4c"}, false},
+		{"silent", map[string]string{}, false},
+	}
+	for _, c := range cases {
+		tty := &fakeTerminal{script: c.script}
+		got, err := Query(tty, 50*time.Millisecond)
+		if err != nil {
+			t.Errorf("%s: %s", c.name, err)
+		}
+		if got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
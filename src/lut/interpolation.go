@@ -0,0 +1,71 @@
+package lut
+
+// Interpolation blends the table entries at the corners of the cell with its lowest corner at
+// lattice, for a colour frac of the way across the cell on each channel.
+type Interpolation func(l *LUT, lattice [3]int, frac [3]float64) [3]float64
+
+// corners returns the entries at the corners of a cell, indexed by a bit for each channel
+// being at the top of the cell: 1 for red, 2 for green and 4 for blue
+func (l *LUT) corners(lattice [3]int) (c [8][3]float64) {
+	for i := range c {
+		c[i] = l.at(lattice[0]+i&1, lattice[1]+i>>1&1, lattice[2]+i>>2&1)
+	}
+	return c
+}
+
+func lerp(a, b [3]float64, t float64) (out [3]float64) {
+	for i := range out {
+		out[i] = a[i] + (b[i]-a[i])*t
+	}
+	return out
+}
+
+// Trilinear blends all eight corners of the cell, one channel at a time.
+func Trilinear(l *LUT, lattice [3]int, frac [3]float64) [3]float64 {
+	c := l.corners(lattice)
+	r0 := lerp(c[0], c[1], frac[0])
+	r1 := lerp(c[2], c[3], frac[0])
+	r2 := lerp(c[4], c[5], frac[0])
+	r3 := lerp(c[6], c[7], frac[0])
+	return lerp(lerp(r0, r1, frac[1]), lerp(r2, r3, frac[1]), frac[2])
+}
+
+// Tetrahedral blends the four corners of the tetrahedron of the cell the colour falls in.
+// It's what grading software uses, as it keeps greys grey and is cheaper than Trilinear.
+func Tetrahedral(l *LUT, lattice [3]int, frac [3]float64) (out [3]float64) {
+	c := l.corners(lattice)
+	r, g, b := frac[0], frac[1], frac[2]
+
+	// walk from the black corner to the white one, taking the channels largest first
+	var path [4]int
+	var weights [3]float64
+	switch {
+	case r > g && g > b:
+		path, weights = [4]int{0, 1, 3, 7}, [3]float64{r, g, b}
+	case r > b && b >= g:
+		path, weights = [4]int{0, 1, 5, 7}, [3]float64{r, b, g}
+	case b >= r && r > g:
+		path, weights = [4]int{0, 4, 5, 7}, [3]float64{b, r, g}
+	case b > g && g >= r:
+		path, weights = [4]int{0, 4, 6, 7}, [3]float64{b, g, r}
+	case g >= b && b > r:
+		path, weights = [4]int{0, 2, 6, 7}, [3]float64{g, b, r}
+	default:
+		path, weights = [4]int{0, 2, 3, 7}, [3]float64{g, r, b}
+	}
+
+	out = c[path[0]]
+	for step, w := range weights {
+		from, to := c[path[step]], c[path[step+1]]
+		for i := range out {
+			out[i] += (to[i] - from[i]) * w
+		}
+	}
+	return out
+}
+
+// Interpolations is the mapping of name to interpolation.
+var Interpolations = map[string]Interpolation{
+	"trilinear":   Trilinear,
+	"tetrahedral": Tetrahedral,
+}
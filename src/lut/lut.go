@@ -0,0 +1,161 @@
+package lut
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// LUT is a 3D colour lookup table, as graded in Resolve and friends. The table is a Size
+// cube of output colours sampled evenly over the domain, with red changing fastest.
+type LUT struct {
+	Title                string
+	Size                 int
+	DomainMin, DomainMax [3]float64
+	Table                [][3]float64
+}
+
+// Load reads the .cube file at path.
+func Load(path string) (*LUT, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// MaxSize is the largest LUT_3D_SIZE the .cube format allows
+const MaxSize = 256
+
+// Parse reads a LUT in the Adobe .cube format. Only 3D tables are supported.
+// LUT_3D_INPUT_RANGE, as Resolve writes it, is read as the same domain for every channel.
+func Parse(r io.Reader) (*LUT, error) {
+	l := &LUT{DomainMax: [3]float64{1, 1, 1}}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		var err error
+		switch fields[0] {
+		case "TITLE":
+			l.Title = strings.Trim(strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "TITLE")), `"`)
+		case "LUT_3D_SIZE":
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("lut: line %d: LUT_3D_SIZE wants one value", line)
+			}
+			if l.Size, err = strconv.Atoi(fields[1]); err != nil {
+				break
+			}
+			if l.Size < 2 || l.Size > MaxSize {
+				return nil, fmt.Errorf("lut: line %d: size %d isn't from 2 to %d", line, l.Size, MaxSize)
+			}
+			l.Table = make([][3]float64, 0, l.Size*l.Size*l.Size)
+		case "LUT_1D_SIZE":
+			return nil, fmt.Errorf("lut: line %d: 1D LUTs aren't supported", line)
+		case "DOMAIN_MIN":
+			l.DomainMin, err = triple(fields[1:])
+		case "DOMAIN_MAX":
+			l.DomainMax, err = triple(fields[1:])
+		case "LUT_3D_INPUT_RANGE":
+			var lo, hi float64
+			if lo, hi, err = pair(fields[1:]); err == nil {
+				l.DomainMin, l.DomainMax = [3]float64{lo, lo, lo}, [3]float64{hi, hi, hi}
+			}
+		default:
+			var rgb [3]float64
+			if rgb, err = triple(fields); err != nil {
+				// anything that isn't a number is a keyword we don't know, which the format says to skip
+				if _, numeric := strconv.ParseFloat(fields[0], 64); numeric != nil {
+					continue
+				}
+				break
+			}
+			if l.Size == 0 {
+				return nil, fmt.Errorf("lut: line %d: table data before LUT_3D_SIZE", line)
+			}
+			if len(l.Table) == cap(l.Table) {
+				return nil, fmt.Errorf("lut: line %d: more table entries than LUT_3D_SIZE %d has room for", line, l.Size)
+			}
+			l.Table = append(l.Table, rgb)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("lut: line %d: %w", line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if l.Size == 0 {
+		return nil, fmt.Errorf("lut: no LUT_3D_SIZE")
+	}
+	if want := l.Size * l.Size * l.Size; len(l.Table) != want {
+		return nil, fmt.Errorf("lut: %d table entries, want %d", len(l.Table), want)
+	}
+	for i := range l.DomainMin {
+		if l.DomainMax[i] <= l.DomainMin[i] {
+			return nil, fmt.Errorf("lut: empty domain")
+		}
+	}
+	return l, nil
+}
+
+// triple parses three floats
+func triple(fields []string) (v [3]float64, err error) {
+	if len(fields) != 3 {
+		return v, fmt.Errorf("want 3 values, got %d", len(fields))
+	}
+	for i, f := range fields {
+		if v[i], err = strconv.ParseFloat(f, 64); err != nil {
+			return v, err
+		}
+	}
+	return v, nil
+}
+
+// pair parses two floats
+func pair(fields []string) (lo, hi float64, err error) {
+	if len(fields) != 2 {
+		return 0, 0, fmt.Errorf("want 2 values, got %d", len(fields))
+	}
+	if lo, err = strconv.ParseFloat(fields[0], 64); err != nil {
+		return 0, 0, err
+	}
+	hi, err = strconv.ParseFloat(fields[1], 64)
+	return lo, hi, err
+}
+
+// at returns the table entry at the lattice point r, g, b
+func (l *LUT) at(r, g, b int) [3]float64 {
+	return l.Table[(b*l.Size+g)*l.Size+r]
+}
+
+// Apply looks up the graded colour of c, interpolating between lattice points with interp.
+func (l *LUT) Apply(c color.RGBA, interp Interpolation) color.RGBA {
+	var lattice [3]int
+	var frac [3]float64
+	for i, v := range [3]uint8{c.R, c.G, c.B} {
+		// position of the channel in the lattice, the top cell takes the very top of the domain
+		p := (float64(v)/255 - l.DomainMin[i]) / (l.DomainMax[i] - l.DomainMin[i]) * float64(l.Size-1)
+		p = math.Max(0, math.Min(float64(l.Size-1), p))
+		lattice[i] = int(math.Min(p, float64(l.Size-2)))
+		frac[i] = p - float64(lattice[i])
+	}
+	out := interp(l, lattice, frac)
+
+	channel := func(v float64) uint8 {
+		return uint8(math.Max(0, math.Min(1, v))*255 + 0.5)
+	}
+	return color.RGBA{R: channel(out[0]), G: channel(out[1]), B: channel(out[2]), A: c.A}
+}
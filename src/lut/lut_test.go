@@ -0,0 +1,112 @@
+package lut
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+// cube writes a .cube file of size with every lattice point mapped through grade
+func cube(size int, header string, grade func(r, g, b float64) (float64, float64, float64)) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# made for the tests\n%sLUT_3D_SIZE %d\n", header, size)
+	top := float64(size - 1)
+	for b := 0; b < size; b++ {
+		for g := 0; g < size; g++ {
+			for r := 0; r < size; r++ {
+				or, og, ob := grade(float64(r)/top, float64(g)/top, float64(b)/top)
+				fmt.Fprintf(&sb, "%f %f %f\n", or, og, ob)
+			}
+		}
+	}
+	return sb.String()
+}
+
+func identity(r, g, b float64) (float64, float64, float64) { return r, g, b }
+
+// testColours cover the corners of the cube and the inside of cells
+var testColours = []color.RGBA{
+	{A: 255}, {R: 255, G: 255, B: 255, A: 255}, {R: 200, G: 100, B: 50, A: 255},
+	{R: 17, G: 240, B: 129, A: 128}, {R: 90, G: 90, B: 90, A: 0}, {R: 1, G: 254, B: 127, A: 255},
+}
+
+func TestApplyIdentity(t *testing.T) {
+	for _, size := range []int{2, 17} {
+		l, err := Parse(strings.NewReader(cube(size, `TITLE "identity"`+"\n", identity)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if l.Title != "identity" {
+			t.Errorf("got title %q", l.Title)
+		}
+		for name, interp := range Interpolations {
+			for _, c := range testColours {
+				if got := l.Apply(c, interp); got != c {
+					t.Errorf("size %d %s: %v came out as %v", size, name, c, got)
+				}
+			}
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	invert := func(r, g, b float64) (float64, float64, float64) { return 1 - r, 1 - g, 1 - b }
+	swap := func(r, g, b float64) (float64, float64, float64) { return b, r, g }
+	cases := []struct {
+		name   string
+		header string
+		grade  func(r, g, b float64) (float64, float64, float64)
+		in     color.RGBA
+		want   color.RGBA
+	}{
+		{"invert", "", invert, color.RGBA{R: 200, G: 100, B: 50, A: 255}, color.RGBA{R: 55, G: 155, B: 205, A: 255}},
+		{"swap", "", swap, color.RGBA{R: 200, G: 100, B: 50, A: 7}, color.RGBA{R: 50, G: 200, B: 100, A: 7}},
+		// the table covers 0 to 0.5, so everything above is clamped to the top of it
+		{"domain", "DOMAIN_MAX 0.5 0.5 0.5\n", identity, color.RGBA{R: 51, G: 200, A: 255}, color.RGBA{R: 102, G: 255, A: 255}},
+		{"input range", "LUT_3D_INPUT_RANGE 0 0.5\n", identity, color.RGBA{R: 51, G: 200, A: 255}, color.RGBA{R: 102, G: 255, A: 255}},
+	}
+	for _, c := range cases {
+		l, err := Parse(strings.NewReader(cube(5, c.header, c.grade)))
+		if err != nil {
+			t.Fatalf("%s: %s", c.name, err)
+		}
+		for name, interp := range Interpolations {
+			if got := l.Apply(c.in, interp); got != c.want {
+				t.Errorf("%s %s: got %v, want %v", c.name, name, got, c.want)
+			}
+		}
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	whole := cube(2, "", identity)
+	short := whole[:strings.LastIndex(whole[:len(whole)-1], "\n")+1]
+	cases := map[string]string{
+		"empty":              "",
+		"no size":            "TITLE \"nothing\"\n",
+		"huge size":          "LUT_3D_SIZE 3000000\n",
+		"size of one":        "LUT_3D_SIZE 1\n0 0 0\n",
+		"bad size":           "LUT_3D_SIZE two\n",
+		"1D":                 "LUT_1D_SIZE 2\n0 0 0\n1 1 1\n",
+		"short table":        short,
+		"long table":         whole + "1 1 1\n",
+		"two values":         strings.Replace(whole, "1.000000 1.000000 1.000000", "1 1", 1),
+		"bad number":         strings.Replace(whole, "1.000000 1.000000 1.000000", "1 1 x", 1),
+		"empty domain":       "DOMAIN_MIN 1 0 0\n" + whole,
+		"short domain":       "DOMAIN_MAX 1 1\n" + whole,
+		"bad input range":    "LUT_3D_INPUT_RANGE 0\n" + whole,
+		"empty input range":  "LUT_3D_INPUT_RANGE 1 0\n" + whole,
+		"data before header": "0 0 0\n" + whole,
+	}
+	for name, data := range cases {
+		if _, err := Parse(strings.NewReader(data)); err == nil {
+			t.Errorf("%s: parsed without error", name)
+		}
+	}
+
+	// keywords that aren't known are skipped
+	if _, err := Parse(strings.NewReader("LUT_3D_SHAPER whatever\n" + whole)); err != nil {
+		t.Errorf("unknown keyword: %s", err)
+	}
+}
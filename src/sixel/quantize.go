@@ -0,0 +1,146 @@
+package sixel
+
+import (
+	"image"
+	"image/color"
+	"sort"
+)
+
+// maxSamples bounds how many pixels the median cut looks at, big frames are sampled evenly
+const maxSamples = 1 << 16
+
+// box is a set of colours being split by the median cut
+type box []color.RGBA
+
+// channel returns the value of the i-th channel of c
+func channel(c color.RGBA, i int) uint8 {
+	switch i {
+	case 0:
+		return c.R
+	case 1:
+		return c.G
+	default:
+		return c.B
+	}
+}
+
+// widest returns the channel with the largest range in the box, and that range
+func (b box) widest() (ch int, spread int) {
+	for i := 0; i < 3; i++ {
+		lo, hi := uint8(255), uint8(0)
+		for _, c := range b {
+			v := channel(c, i)
+			if v < lo {
+				lo = v
+			}
+			if v > hi {
+				hi = v
+			}
+		}
+		if int(hi)-int(lo) > spread {
+			ch, spread = i, int(hi)-int(lo)
+		}
+	}
+	return ch, spread
+}
+
+// mean is the colour that represents the box in the palette
+func (b box) mean() color.RGBA {
+	var r, g, bl int
+	for _, c := range b {
+		r, g, bl = r+int(c.R), g+int(c.G), bl+int(c.B)
+	}
+	n := len(b)
+	return color.RGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(bl / n), A: 255}
+}
+
+// median returns where to split a box sorted on the channel ch, as near the middle as it can be
+// without splitting a run of the same value, which would leave the same colour in both halves.
+// The box must have some spread on the channel.
+func (b box) median(ch int) int {
+	same := func(i int) bool { return channel(b[i-1], ch) == channel(b[i], ch) }
+	below, above := len(b)/2, len(b)/2
+	for below > 0 && same(below) {
+		below--
+	}
+	for above < len(b) && same(above) {
+		above++
+	}
+	if below == 0 || (above < len(b) && above-len(b)/2 < len(b)/2-below) {
+		return above
+	}
+	return below
+}
+
+// MedianCut builds an adaptive palette of at most n colours for the image by repeatedly
+// splitting the box with the widest channel at its median.
+func MedianCut(img *image.RGBA, n int) color.Palette {
+	bounds := img.Bounds()
+	stride := 1
+	if pixels := bounds.Dx() * bounds.Dy(); pixels > maxSamples {
+		stride = pixels / maxSamples
+	}
+
+	samples := box{}
+	for i := 0; i < bounds.Dx()*bounds.Dy(); i += stride {
+		samples = append(samples, img.RGBAAt(bounds.Min.X+i%bounds.Dx(), bounds.Min.Y+i/bounds.Dx()))
+	}
+	if len(samples) == 0 {
+		return color.Palette{color.RGBA{A: 255}}
+	}
+
+	boxes := []box{samples}
+	for len(boxes) < n {
+		// pick the box with the most to gain from a split
+		pick, pickCh, pickSpread := -1, 0, 0
+		for i, b := range boxes {
+			if len(b) < 2 {
+				continue
+			}
+			if ch, spread := b.widest(); spread > pickSpread {
+				pick, pickCh, pickSpread = i, ch, spread
+			}
+		}
+		if pick < 0 {
+			break
+		}
+
+		b := boxes[pick]
+		sort.Slice(b, func(i, j int) bool { return channel(b[i], pickCh) < channel(b[j], pickCh) })
+		cut := b.median(pickCh)
+		boxes[pick] = b[:cut]
+		boxes = append(boxes, b[cut:])
+	}
+
+	palette := make(color.Palette, len(boxes))
+	for i, b := range boxes {
+		palette[i] = b.mean()
+	}
+	return palette
+}
+
+// cacheBits is the precision per channel of the nearest colour cache
+const cacheBits = 6
+
+// indexer finds the nearest palette entry to a colour, remembering previous answers
+type indexer struct {
+	palette color.Palette
+	cache   []int16
+}
+
+func newIndexer(palette color.Palette) *indexer {
+	cache := make([]int16, 1<<(3*cacheBits))
+	for i := range cache {
+		cache[i] = -1
+	}
+	return &indexer{palette: palette, cache: cache}
+}
+
+func (ix *indexer) index(c color.RGBA) int {
+	const shift = 8 - cacheBits
+	key := int(c.R>>shift)<<(2*cacheBits) | int(c.G>>shift)<<cacheBits | int(c.B>>shift)
+	if ix.cache[key] < 0 {
+		ix.cache[key] = int16(ix.palette.Index(c))
+	}
+	return int(ix.cache[key])
+}
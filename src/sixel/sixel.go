@@ -0,0 +1,162 @@
+package sixel
+
+import (
+	"bufio"
+	"image"
+	"image/color"
+	"io"
+	"strconv"
+
+	"wombatlord/photerm/src/dither"
+)
+
+// Sixel data is framed by a device control string and the string terminator.
+// P2=1 leaves pixels with no sixel set at their current colour instead of painting the background.
+const (
+	Start = "\u001bP0;1;0q"
+	End   = "\u001b\\"
+)
+
+// MaxColours is the number of colour registers most sixel terminals provide
+const MaxColours = 256
+
+// orderedStep sizes the noise of ordered dithering. An adaptive palette is much
+// denser than a fixed cube, so the noise is kept small.
+const orderedStep = 16
+
+// Encoder writes images to an io.Writer as sixel graphics.
+type Encoder struct {
+	w io.Writer
+
+	// Colours is the size of the adaptive palette, at most MaxColours
+	Colours int
+	// Dither is applied when mapping pixels onto the palette, nil disables it
+	Dither dither.Method
+}
+
+// NewEncoder returns an Encoder writing to w with a full palette and no dithering.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w, Colours: MaxColours}
+}
+
+// Encode writes img as a single sixel image.
+func (e *Encoder) Encode(img *image.RGBA) error {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	palette := MedianCut(img, e.Colours)
+	pixels := e.indexed(img, palette)
+
+	out := bufio.NewWriter(e.w)
+	out.WriteString(Start)
+
+	// raster attributes: square pixels and the size of the image
+	out.WriteString("\"1;1;" + strconv.Itoa(width) + ";" + strconv.Itoa(height))
+
+	// define the colour registers, sixel wants the channels as percentages
+	for i, c := range palette {
+		r, g, b, _ := c.RGBA()
+		out.WriteString("#" + strconv.Itoa(i) + ";2;" +
+			strconv.Itoa(int(r*100/0xffff)) + ";" + strconv.Itoa(int(g*100/0xffff)) + ";" + strconv.Itoa(int(b*100/0xffff)))
+	}
+
+	// each band is six rows of pixels, painted one colour at a time
+	for top := 0; top < height; top += 6 {
+		// gather the sixels of every colour used in the band in a single pass
+		rows := map[int][]byte{}
+		for dy := 0; dy < 6 && top+dy < height; dy++ {
+			for x := 0; x < width; x++ {
+				c := pixels[(top+dy)*width+x]
+				if rows[c] == nil {
+					rows[c] = make([]byte, width)
+				}
+				rows[c][x] |= 1 << dy
+			}
+		}
+
+		first := true
+		for c := range palette {
+			row, ok := rows[c]
+			if !ok {
+				continue
+			}
+			// go back to the start of the band for every colour after the first
+			if !first {
+				out.WriteByte('$')
+			}
+			first = false
+
+			for x := range row {
+				row[x] += '?'
+			}
+			out.WriteString("#" + strconv.Itoa(c))
+			writeRLE(out, row)
+		}
+		out.WriteByte('-')
+	}
+
+	out.WriteString(End)
+	return out.Flush()
+}
+
+// indexed maps every pixel of img to its palette index, dithering if the encoder asks for it
+func (e *Encoder) indexed(img *image.RGBA, palette color.Palette) []int {
+	bounds := img.Bounds()
+	ix := newIndexer(palette)
+	pixels := make([]int, 0, bounds.Dx()*bounds.Dy())
+
+	if e.Dither == nil {
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				pixels = append(pixels, ix.index(img.RGBAAt(x, y)))
+			}
+		}
+		return pixels
+	}
+
+	plane := dither.NewPlane(bounds.Dx(), bounds.Dy(), 3)
+	for y := 0; y < plane.H; y++ {
+		for x := 0; x < plane.W; x++ {
+			c := img.RGBAAt(bounds.Min.X+x, bounds.Min.Y+y)
+			px := plane.At(x, y)
+			px[0], px[1], px[2] = float64(c.R), float64(c.G), float64(c.B)
+		}
+	}
+	e.Dither.Dither(plane, orderedStep, func(px []float64) {
+		i := ix.index(color.RGBA{R: clamp(px[0]), G: clamp(px[1]), B: clamp(px[2]), A: 255})
+		pixels = append(pixels, i)
+		r, g, b, _ := palette[i].RGBA()
+		px[0], px[1], px[2] = float64(r>>8), float64(g>>8), float64(b>>8)
+	})
+	return pixels
+}
+
+// writeRLE writes a row of sixels, collapsing runs with the repeat introducer
+func writeRLE(out *bufio.Writer, row []byte) {
+	for i := 0; i < len(row); {
+		run := 1
+		for i+run < len(row) && row[i+run] == row[i] {
+			run++
+		}
+		// the repeat introducer only pays for itself on runs longer than three
+		if run > 3 {
+			out.WriteString("!" + strconv.Itoa(run))
+			out.WriteByte(row[i])
+		} else {
+			for j := 0; j < run; j++ {
+				out.WriteByte(row[i])
+			}
+		}
+		i += run
+	}
+}
+
+// clamp rounds a float sample to the nearest byte
+func clamp(f float64) uint8 {
+	if f <= 0 {
+		return 0
+	}
+	if f >= 255 {
+		return 255
+	}
+	return uint8(f + 0.5)
+}
@@ -0,0 +1,107 @@
+package sixel
+
+import (
+	"bufio"
+	"bytes"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+var (
+	red   = color.RGBA{R: 255, A: 255}
+	green = color.RGBA{G: 255, A: 255}
+	blue  = color.RGBA{B: 255, A: 255}
+	white = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+)
+
+// striped returns an image with a row of each colour from the top
+func striped(width int, rows ...color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, len(rows)))
+	for y, c := range rows {
+		for x := 0; x < width; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestWriteRLE(t *testing.T) {
+	cases := []struct {
+		name, row, want string
+	}{
+		{"single", "A", "A"},
+		{"runs of one", "ABA", "ABA"},
+		{"run of three", "BBBA", "BBBA"},
+		{"run of four", "CCCCA", "!4CA"},
+		{"long run", "D" + strings.Repeat("E", 300) + "D", "D!300ED"},
+		{"all the runs", "A" + "BBB" + "CCCC" + strings.Repeat("D", 256), "ABBB!4C!256D"},
+	}
+	for _, c := range cases {
+		var buf bytes.Buffer
+		out := bufio.NewWriter(&buf)
+		writeRLE(out, []byte(c.row))
+		out.Flush()
+		if got := buf.String(); got != c.want {
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestMedianCut(t *testing.T) {
+	img := striped(5, red, green, blue, white)
+
+	palette := MedianCut(img, MaxColours)
+	if len(palette) != 4 {
+		t.Fatalf("got %d colours, want 4: %v", len(palette), palette)
+	}
+	for _, c := range []color.RGBA{red, green, blue, white} {
+		if palette[palette.Index(c)] != c {
+			t.Errorf("%v isn't in the palette %v", c, palette)
+		}
+	}
+
+	// fewer registers than colours, every colour gets a box of its own or shares the mean
+	palette = MedianCut(img, 2)
+	if len(palette) != 2 {
+		t.Fatalf("got %d colours, want 2: %v", len(palette), palette)
+	}
+
+	if palette := MedianCut(striped(3, red, red), MaxColours); len(palette) != 1 || palette[0] != red {
+		t.Errorf("a flat image gave %v", palette)
+	}
+
+	// the median falls among the blues, but the cut moves to where blue meets red
+	if palette := MedianCut(striped(3, blue, blue, blue, blue, blue, blue, red), MaxColours); len(palette) != 2 {
+		t.Errorf("six blue rows and a red one gave %v", palette)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	cases := []struct {
+		name string
+		img  *image.RGBA
+		want string
+	}{
+		{
+			// the split is on red, so the blue half with no red comes first
+			"two rows", striped(4, red, blue),
+			`"1;1;4;2` + "#0;2;0;0;100#1;2;100;0;0" + "#0!4A$#1!4@-",
+		},
+		{
+			// seven rows is two bands, the second with only its top row
+			"two bands", striped(3, blue, blue, blue, blue, blue, blue, red),
+			`"1;1;3;7` + "#0;2;0;0;100#1;2;100;0;0" + "#0~~~-" + "#1@@@-",
+		},
+	}
+	for _, c := range cases {
+		var buf bytes.Buffer
+		if err := NewEncoder(&buf).Encode(c.img); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := buf.String(), Start+c.want+End; got != want {
+			t.Errorf("%s:\ngot  %q\nwant %q", c.name, got, want)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+
+package term
+
+type state struct{}
+
+func isTerminal(uintptr) bool { return false }
+
+func makeRaw(uintptr) (*state, error) { return nil, ErrUnsupported }
+
+func restore(uintptr, *state) {}
+
+func getSize(uintptr) (Size, error) { return Size{}, ErrUnsupported }
@@ -0,0 +1,63 @@
+//go:build linux || darwin
+
+package term
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// state is the terminal configuration saved by makeRaw
+type state struct {
+	termios syscall.Termios
+}
+
+func ioctl(fd uintptr, req uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func isTerminal(fd uintptr) bool {
+	var t syscall.Termios
+	return ioctl(fd, ioctlGetTermios, unsafe.Pointer(&t)) == nil
+}
+
+// makeRaw turns off echo and line buffering, and has reads time out after a tenth of a second
+func makeRaw(fd uintptr) (*state, error) {
+	var old syscall.Termios
+	if err := ioctl(fd, ioctlGetTermios, unsafe.Pointer(&old)); err != nil {
+		return nil, err
+	}
+
+	raw := old
+	raw.Lflag &^= syscall.ECHO | syscall.ICANON
+	raw.Cc[syscall.VMIN] = 0
+	raw.Cc[syscall.VTIME] = 1
+	if err := ioctl(fd, ioctlSetTermios, unsafe.Pointer(&raw)); err != nil {
+		return nil, err
+	}
+	return &state{termios: old}, nil
+}
+
+func restore(fd uintptr, st *state) {
+	_ = ioctl(fd, ioctlSetTermios, unsafe.Pointer(&st.termios))
+}
+
+// winsize is the kernel's struct winsize
+type winsize struct {
+	Rows, Cols, XPixel, YPixel uint16
+}
+
+func getSize(fd uintptr) (Size, error) {
+	var ws winsize
+	if err := ioctl(fd, syscall.TIOCGWINSZ, unsafe.Pointer(&ws)); err != nil {
+		return Size{}, err
+	}
+	size := Size{Cols: int(ws.Cols), Rows: int(ws.Rows)}
+	if ws.Cols > 0 && ws.Rows > 0 {
+		size.CellWidth, size.CellHeight = int(ws.XPixel/ws.Cols), int(ws.YPixel/ws.Rows)
+	}
+	return size, nil
+}
@@ -0,0 +1,66 @@
+package term
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Size is how big the terminal is in cells, and how big a cell is in pixels.
+// The cell size is zero when the terminal hasn't said.
+type Size struct {
+	Cols, Rows            int
+	CellWidth, CellHeight int
+}
+
+// GetSize asks the kernel for the size of the terminal f is connected to. Plenty of
+// terminals don't fill in the pixel size, QueryCellSize can ask them instead.
+func GetSize(f *os.File) (Size, error) {
+	return getSize(f.Fd())
+}
+
+// Queries for the pixel size of a cell, and failing that of the text area. The
+// primary device attributes go last, their reply marks the end of the conversation.
+const (
+	cellSizeQuery = "\u001b[16t"
+	textAreaQuery = "\u001b[14t"
+	primaryDA     = "\u001b[c"
+)
+
+// windowReport matches the replies to the size queries, ESC [ 6 ; h ; w t for a cell
+// and ESC [ 4 ; h ; w t for the text area
+var windowReport = regexp.MustCompile("\u001b\\[([46]);(\\d+);(\\d+)t")
+
+// QueryCellSize asks the terminal on rw for the pixel size of its cells and returns size with
+// them filled in. Where only the text area is reported it's divided up by the cells of size.
+// A terminal that doesn't answer leaves size as it was.
+func QueryCellSize(rw io.ReadWriter, size Size, timeout time.Duration) (Size, error) {
+	if _, err := io.WriteString(rw, cellSizeQuery+textAreaQuery+primaryDA); err != nil {
+		return size, err
+	}
+	reply, err := ReadUntil(rw, timeout, func(reply []byte) bool {
+		i := bytes.Index(reply, []byte("\u001b[?"))
+		return i >= 0 && bytes.IndexByte(reply[i:], 'c') >= 0
+	})
+
+	var cell, area [2]int
+	for _, m := range windowReport.FindAllSubmatch(reply, -1) {
+		h, _ := strconv.Atoi(string(m[2]))
+		w, _ := strconv.Atoi(string(m[3]))
+		if m[1][0] == '6' {
+			cell = [2]int{w, h}
+		} else {
+			area = [2]int{w, h}
+		}
+	}
+	switch {
+	case cell[0] > 0 && cell[1] > 0:
+		size.CellWidth, size.CellHeight = cell[0], cell[1]
+	case area[0] > 0 && area[1] > 0 && size.Cols > 0 && size.Rows > 0:
+		size.CellWidth, size.CellHeight = area[0]/size.Cols, area[1]/size.Rows
+	}
+	return size, err
+}
@@ -0,0 +1,77 @@
+package term
+
+import (
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+// ErrUnsupported is returned on platforms where photerm doesn't know how to talk to the terminal.
+var ErrUnsupported = errors.New("term: not supported on this platform")
+
+// TTY is the controlling terminal, opened in raw mode so that the replies
+// to queries can be read without waiting for a newline.
+type TTY struct {
+	f     *os.File
+	state *state
+}
+
+// Open opens the controlling terminal for querying. Reads return after
+// a short while even when there's nothing to read, so a reader can give up
+// on a terminal that never replies. Close restores the terminal.
+func Open() (*TTY, error) {
+	f, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	st, err := makeRaw(f.Fd())
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &TTY{f: f, state: st}, nil
+}
+
+// Read reads whatever the terminal has sent. A read that times out comes back
+// empty rather than with io.EOF, as the terminal hasn't gone anywhere.
+func (t *TTY) Read(p []byte) (int, error) {
+	n, err := t.f.Read(p)
+	if n == 0 && err == io.EOF {
+		return 0, nil
+	}
+	return n, err
+}
+
+func (t *TTY) Write(p []byte) (int, error) { return t.f.Write(p) }
+
+// Close puts the terminal back the way Open found it.
+func (t *TTY) Close() error {
+	restore(t.f.Fd(), t.state)
+	return t.f.Close()
+}
+
+// IsTerminal reports whether f is connected to a terminal.
+func IsTerminal(f *os.File) bool {
+	return isTerminal(f.Fd())
+}
+
+// ReadUntil collects what r has to say until done is happy with it, r runs dry
+// or the timeout passes. Whatever was read is returned in every case, the error
+// is only set when reading failed.
+func ReadUntil(r io.Reader, timeout time.Duration, done func(reply []byte) bool) ([]byte, error) {
+	deadline := time.Now().Add(timeout)
+	reply := []byte{}
+	buf := make([]byte, 256)
+	for time.Now().Before(deadline) {
+		n, err := r.Read(buf)
+		reply = append(reply, buf[:n]...)
+		if done(reply) || err == io.EOF {
+			return reply, nil
+		}
+		if err != nil {
+			return reply, err
+		}
+	}
+	return reply, nil
+}
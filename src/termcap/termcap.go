@@ -0,0 +1,170 @@
+package termcap
+
+import (
+	"bytes"
+	"image/color"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"wombatlord/photerm/src/colour"
+	"wombatlord/photerm/src/kitty"
+	"wombatlord/photerm/src/term"
+)
+
+// Colours is the number of colours a terminal can show
+type Colours int
+
+const (
+	Colours16  Colours = 16
+	Colours256 Colours = 256
+	TrueColour Colours = 1 << 24
+)
+
+// Caps is what a terminal is known to be capable of.
+type Caps struct {
+	// Graphics protocols for drawing real pixels
+	Sixel, Kitty, ITerm bool
+	// Asked is set when the terminal answered the queries, so Sixel and Kitty are its own
+	// word rather than a guess from the environment. ITerm is always a guess.
+	Asked bool
+
+	Colours Colours
+	// Unicode is false when only ASCII can be relied on
+	Unicode bool
+	// Background is the colour behind the text, with an alpha of 0 when it isn't known
+	Background color.RGBA
+}
+
+// FromEnv makes an educated guess at the capabilities of the terminal from the environment,
+// as read through getenv. It never talks to the terminal so it's safe to call on pipes.
+func FromEnv(getenv func(string) string) Caps {
+	termName := getenv("TERM")
+	program := getenv("TERM_PROGRAM")
+
+	caps := Caps{Colours: Colours16}
+	switch {
+	case termName == "dumb" || termName == "linux":
+		// the linux console has 16 colours and a 512 glyph font, the dumb terminal has nothing
+		return caps
+	case strings.Contains(termName, "256color"):
+		caps.Colours = Colours256
+	case strings.HasSuffix(termName, "-direct"):
+		caps.Colours = TrueColour
+	}
+
+	switch colorterm := getenv("COLORTERM"); colorterm {
+	case "truecolor", "24bit":
+		caps.Colours = TrueColour
+	}
+
+	switch {
+	case termName == "xterm-kitty" || getenv("KITTY_WINDOW_ID") != "":
+		caps.Kitty, caps.Colours = true, TrueColour
+	case program == "iTerm.app" || getenv("LC_TERMINAL") == "iTerm2":
+		caps.ITerm, caps.Colours = true, TrueColour
+	case program == "WezTerm":
+		caps.ITerm, caps.Sixel, caps.Colours = true, true, TrueColour
+	case termName == "foot" || strings.HasPrefix(termName, "foot-") || termName == "mlterm":
+		caps.Sixel, caps.Colours = true, TrueColour
+	}
+
+	// the locale decides whether multi-byte glyphs come out right
+	locale := getenv("LC_ALL")
+	if locale == "" {
+		locale = getenv("LC_CTYPE")
+	}
+	if locale == "" {
+		locale = getenv("LANG")
+	}
+	locale = strings.ToLower(locale)
+	caps.Unicode = strings.Contains(locale, "utf-8") || strings.Contains(locale, "utf8")
+
+	// rxvt and friends export their colours as fg;bg, sometimes with a field between
+	if fgbg := strings.Split(getenv("COLORFGBG"), ";"); len(fgbg) >= 2 {
+		if bg, err := strconv.Atoi(fgbg[len(fgbg)-1]); err == nil && bg >= 0 && bg < 16 {
+			caps.Background = colour.ANSI16.Colour(uint8(bg))
+		}
+	}
+
+	return caps
+}
+
+// Queries sent to the terminal. The primary device attributes go last as every
+// terminal answers them, so their reply marks the end of the conversation.
+const (
+	// xtgettcap asks for the Tc and RGB terminfo capabilities, hex encoded
+	xtgettcap = "\u001bP+q5463;524742\u001b\\"
+	// background asks for the background colour with OSC 11
+	background = "\u001b]11;?\u001b\\"
+	primaryDA  = "\u001b[c"
+)
+
+// Query asks the terminal on rw about the capabilities that can't be guessed from the
+// environment and returns caps updated with the answers. Terminals that don't
+// understand a query ignore it, so a silence just leaves caps as it was, apart from kitty
+// graphics: the variables kitty sets are passed on to tmux, screen and ssh sessions that
+// can't draw its images, so only an answer to the kitty query counts.
+func Query(rw io.ReadWriter, caps Caps, timeout time.Duration) (Caps, error) {
+	if _, err := io.WriteString(rw, xtgettcap+kitty.QueryCommand+background+primaryDA); err != nil {
+		return caps, err
+	}
+	reply, err := term.ReadUntil(rw, timeout, func(reply []byte) bool {
+		_, ok := deviceAttributes(reply)
+		return ok
+	})
+
+	if attrs, ok := deviceAttributes(reply); ok {
+		caps.Sixel, caps.Asked = false, true
+		for _, attr := range attrs {
+			// attribute 4 is sixel graphics
+			if attr == "4" {
+				caps.Sixel = true
+			}
+		}
+	}
+	if bytes.Contains(reply, []byte("\u001bP1+r5463")) || bytes.Contains(reply, []byte("\u001bP1+r524742")) {
+		caps.Colours = TrueColour
+	}
+	caps.Kitty = kitty.Supported(reply)
+	if bg, ok := backgroundOf(reply); ok {
+		caps.Background = bg
+	}
+	return caps, err
+}
+
+// backgroundReply matches the answer to the background query, rgb:RRRR/GGGG/BBBB with one
+// to four hex digits a channel
+var backgroundReply = regexp.MustCompile(`\x1b\]11;rgb:([0-9a-fA-F]{1,4})/([0-9a-fA-F]{1,4})/([0-9a-fA-F]{1,4})`)
+
+// backgroundOf finds the background colour in the reply
+func backgroundOf(reply []byte) (c color.RGBA, ok bool) {
+	m := backgroundReply.FindSubmatch(reply)
+	if m == nil {
+		return c, false
+	}
+	var rgb [3]uint8
+	for i, hex := range m[1:] {
+		v, _ := strconv.ParseUint(string(hex), 16, 16)
+		top := uint64(1)<<(4*len(hex)) - 1
+		rgb[i] = uint8((v*255 + top/2) / top)
+	}
+	return color.RGBA{R: rgb[0], G: rgb[1], B: rgb[2], A: 255}, true
+}
+
+// deviceAttributes finds the reply to the primary device attributes query, which
+// looks like ESC [ ? 62 ; 4 ; 22 c, and returns the attributes listed in it.
+func deviceAttributes(reply []byte) (attrs []string, ok bool) {
+	start := bytes.Index(reply, []byte("\u001b[?"))
+	if start < 0 {
+		return nil, false
+	}
+	params := reply[start+3:]
+	end := bytes.IndexByte(params, 'c')
+	if end < 0 {
+		return nil, false
+	}
+	return strings.Split(string(params[:end]), ";"), true
+}
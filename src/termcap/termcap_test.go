@@ -0,0 +1,160 @@
+package termcap
+
+import (
+	"bytes"
+	"image/color"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeTerminal answers the queries written to it from a script of query -> reply,
+// like a terminal would, and stays silent about anything it doesn't know.
+type fakeTerminal struct {
+	script  map[string]string
+	replies bytes.Buffer
+}
+
+func (t *fakeTerminal) Write(p []byte) (int, error) {
+	// replies go out in the order the queries came in
+	queries := string(p)
+	for len(queries) > 0 {
+		next, nextAt := "", len(queries)
+		for query := range t.script {
+			if i := strings.Index(queries, query); i >= 0 && i < nextAt {
+				next, nextAt = query, i
+			}
+		}
+		if next == "" {
+			break
+		}
+		t.replies.WriteString(t.script[next])
+		queries = queries[nextAt+len(next):]
+	}
+	return len(p), nil
+}
+
+func (t *fakeTerminal) Read(p []byte) (int, error) {
+	return t.replies.Read(p)
+}
+
+func env(vars map[string]string) func(string) string {
+	return func(key string) string { return vars[key] }
+}
+
+func TestFromEnv(t *testing.T) {
+	cases := []struct {
+		name string
+		vars map[string]string
+		want Caps
+	}{
+		{"dumb", map[string]string{"TERM": "dumb", "LANG": "en_GB.UTF-8"}, Caps{Colours: Colours16}},
+		{"xterm", map[string]string{"TERM": "xterm", "LANG": "C"}, Caps{Colours: Colours16}},
+		{"tmux", map[string]string{"TERM": "tmux-256color", "LANG": "en_US.UTF-8"}, Caps{Colours: Colours256, Unicode: true}},
+		{"truecolor", map[string]string{"TERM": "xterm-256color", "COLORTERM": "truecolor", "LC_ALL": "en_US.utf8"}, Caps{Colours: TrueColour, Unicode: true}},
+		{"kitty", map[string]string{"TERM": "xterm-kitty", "LANG": "en_US.UTF-8"}, Caps{Kitty: true, Colours: TrueColour, Unicode: true}},
+		{"iterm", map[string]string{"TERM": "xterm-256color", "TERM_PROGRAM": "iTerm.app", "LANG": "en_US.UTF-8"}, Caps{ITerm: true, Colours: TrueColour, Unicode: true}},
+		{"foot", map[string]string{"TERM": "foot", "LANG": "en_US.UTF-8"}, Caps{Sixel: true, Colours: TrueColour, Unicode: true}},
+		{"rxvt light", map[string]string{"TERM": "rxvt-unicode-256color", "COLORFGBG": "0;default;15"}, Caps{Colours: Colours256, Background: color.RGBA{R: 255, G: 255, B: 255, A: 255}}},
+	}
+	for _, c := range cases {
+		if got := FromEnv(env(c.vars)); got != c.want {
+			t.Errorf("%s: got %+v, want %+v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestQuery(t *testing.T) {
+	const da1 = "\u001b[c"
+	kittyQuery := "\u001b_Gi=31,s=1,v=1,a=q,t=d,f=24;AAAA\u001b\\"
+
+	cases := []struct {
+		name   string
+		script map[string]string
+		// from is the guess from the environment, 16 colours and nothing else if left empty
+		from Caps
+		want Caps
+	}{
+		{
+			name:   "silent",
+			script: map[string]string{},
+			want:   Caps{Colours: Colours16},
+		},
+		{
+			name:   "vt220",
+			script: map[string]string{da1: "\u001b[?62;1;6c"},
+			want:   Caps{Colours: Colours16, Asked: true},
+		},
+		{
+			name: "xterm with sixel",
+			script: map[string]string{
+				xtgettcap: "\u001bP0+r5463\u001b\\\u001bP1+r524742=382F382F38\u001b\\",
+				da1:       "\u001b[?63;1;2;4;6;9;15;22c",
+			},
+			want: Caps{Sixel: true, Asked: true, Colours: TrueColour},
+		},
+		{
+			name: "kitty",
+			script: map[string]string{
+				xtgettcap:  "\u001bP1+r5463=\u001b\\\u001bP1+r524742=382F382F38\u001b\\",
+				kittyQuery: "\u001b_Gi=31;OK\u001b\\",
+				da1:        "\u001b[?62;c",
+			},
+			want: Caps{Kitty: true, Asked: true, Colours: TrueColour},
+		},
+		{
+			name: "light background",
+			script: map[string]string{
+				background: "\u001b]11;rgb:ffff/fafa/f0f0\u001b\\",
+				da1:        "\u001b[?62;c",
+			},
+			want: Caps{Colours: Colours16, Asked: true, Background: color.RGBA{R: 255, G: 250, B: 240, A: 255}},
+		},
+		{
+			name: "short background",
+			script: map[string]string{
+				background: "\u001b]11;rgb:0/8/f\u0007",
+				da1:        "\u001b[?62;c",
+			},
+			want: Caps{Colours: Colours16, Asked: true, Background: color.RGBA{R: 0, G: 136, B: 255, A: 255}},
+		},
+		{
+			// tmux started from kitty passes KITTY_WINDOW_ID on, but can't show the images
+			name:   "tmux in kitty",
+			script: map[string]string{da1: "\u001b[?1;2;4c"},
+			from:   Caps{Kitty: true, Colours: TrueColour},
+			want:   Caps{Sixel: true, Asked: true, Colours: TrueColour},
+		},
+		{
+			name:   "kitty guessed, silent",
+			script: map[string]string{},
+			from:   Caps{Kitty: true, Colours: TrueColour},
+			want:   Caps{Colours: TrueColour},
+		},
+		{
+			name:   "sixel guessed, denied",
+			script: map[string]string{da1: "\u001b[?62;c"},
+			from:   Caps{Sixel: true, ITerm: true, Colours: TrueColour},
+			want:   Caps{ITerm: true, Asked: true, Colours: TrueColour},
+		},
+		{
+			name:   "sixel guessed, silent",
+			script: map[string]string{},
+			from:   Caps{Sixel: true, Colours: TrueColour},
+			want:   Caps{Sixel: true, Colours: TrueColour},
+		},
+	}
+	for _, c := range cases {
+		if c.from == (Caps{}) {
+			c.from = Caps{Colours: Colours16}
+		}
+		tty := &fakeTerminal{script: c.script}
+		got, err := Query(tty, c.from, 50*time.Millisecond)
+		if err != nil {
+			t.Errorf("%s: %s", c.name, err)
+		}
+		if got != c.want {
+			t.Errorf("%s: got %+v, want %+v", c.name, got, c.want)
+		}
+	}
+}
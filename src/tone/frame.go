@@ -0,0 +1,55 @@
+package tone
+
+import (
+	"image"
+	"image/color"
+)
+
+// Target is what tone mapping applies to.
+type Target string
+
+const (
+	// Glyphs tone maps only the brightness glyphs are chosen by.
+	Glyphs Target = "glyph"
+	// Colours tone maps only the colours glyphs are painted with.
+	Colours Target = "colour"
+	// Both tone maps glyphs and colours alike.
+	Both Target = "both"
+)
+
+// Frame is a frame ready for rendering, with its colours and the brightness to choose glyphs by
+// tone mapped separately. The colours are the embedded image.
+type Frame struct {
+	image.Image
+	// Glyph is the brightness of each pixel for choosing glyphs, with the same bounds as the image
+	Glyph *image.Gray
+}
+
+// Apply tone maps img for the target.
+func (s Settings) Apply(img image.Image, target Target) *Frame {
+	before, after := s.Map(img)
+	b := img.Bounds()
+
+	glyph := image.NewGray(b)
+	levels := before
+	if target != Colours {
+		levels = after
+	}
+	for i, l := range levels {
+		glyph.Pix[i] = byteOf(l)
+	}
+
+	if target == Glyphs {
+		return &Frame{Image: img, Glyph: glyph}
+	}
+	colours := image.NewRGBA(b)
+	i := 0
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+			colours.SetRGBA(x, y, s.Model.Relight(c, after[i]))
+			i++
+		}
+	}
+	return &Frame{Image: colours, Glyph: glyph}
+}
@@ -0,0 +1,117 @@
+package tone
+
+import "sort"
+
+// clipFraction is the share of the darkest and of the brightest pixels that Stretch lets clip,
+// so a few specks of noise don't stop the rest of the image filling the range
+const clipFraction = 0.005
+
+// Stretch does auto-levels, mapping the luminance samples in l linearly so they fill 0 to 1.
+func Stretch(l []float64) {
+	if len(l) == 0 {
+		return
+	}
+	sorted := append([]float64{}, l...)
+	sort.Float64s(sorted)
+	cut := int(float64(len(sorted)) * clipFraction)
+	lo, hi := sorted[cut], sorted[len(sorted)-1-cut]
+	if hi-lo < 1.0/255 {
+		return
+	}
+	for i, v := range l {
+		l[i] = clamp((v - lo) / (hi - lo))
+	}
+}
+
+// bins is the resolution of the histograms Equalize works with
+const bins = 256
+
+// tileSize is roughly how many pixels across a tile of Equalize is
+const tileSize = 16
+
+// Equalize does contrast limited adaptive histogram equalization on the w by h luminance
+// samples in l. Each tile of the image is equalized by its own histogram, clipped at clip
+// times the average bin so flat areas don't turn to noise, and pixels blend the mappings
+// of the four nearest tiles so the seams don't show.
+func Equalize(l []float64, w, h int, clip float64) {
+	if w == 0 || h == 0 {
+		return
+	}
+	tilesX, tilesY := (w+tileSize-1)/tileSize, (h+tileSize-1)/tileSize
+	tw, th := float64(w)/float64(tilesX), float64(h)/float64(tilesY)
+
+	// the mapping of each tile, from bin to equalized luminance
+	maps := make([][bins]float64, tilesX*tilesY)
+	for ty := 0; ty < tilesY; ty++ {
+		for tx := 0; tx < tilesX; tx++ {
+			x0, x1 := int(float64(tx)*tw), int(float64(tx+1)*tw)
+			y0, y1 := int(float64(ty)*th), int(float64(ty+1)*th)
+			maps[ty*tilesX+tx] = tileMapping(l, w, x0, y0, x1, y1, clip)
+		}
+	}
+
+	out := make([]float64, len(l))
+	for y := 0; y < h; y++ {
+		// position between tile centres, and the tiles either side
+		fy := (float64(y)+0.5)/th - 0.5
+		ty0, ty1, wy := neighbours(fy, tilesY)
+		for x := 0; x < w; x++ {
+			fx := (float64(x)+0.5)/tw - 0.5
+			tx0, tx1, wx := neighbours(fx, tilesX)
+
+			bin := binOf(l[y*w+x])
+			top := maps[ty0*tilesX+tx0][bin]*(1-wx) + maps[ty0*tilesX+tx1][bin]*wx
+			btm := maps[ty1*tilesX+tx0][bin]*(1-wx) + maps[ty1*tilesX+tx1][bin]*wx
+			out[y*w+x] = top*(1-wy) + btm*wy
+		}
+	}
+	copy(l, out)
+}
+
+// tileMapping equalizes the histogram of the tile x0, y0 to x1, y1 of l
+func tileMapping(l []float64, w, x0, y0, x1, y1 int, clip float64) (mapping [bins]float64) {
+	var hist [bins]float64
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			hist[binOf(l[y*w+x])]++
+		}
+	}
+	total := float64((x1 - x0) * (y1 - y0))
+
+	// clip the histogram and share what was cut off between every bin
+	limit := clip * total / bins
+	excess := 0.0
+	for i, n := range hist {
+		if n > limit {
+			excess += n - limit
+			hist[i] = limit
+		}
+	}
+	for i := range hist {
+		hist[i] += excess / bins
+	}
+
+	sum := 0.0
+	for i, n := range hist {
+		sum += n
+		mapping[i] = sum / total
+	}
+	return mapping
+}
+
+// neighbours returns the tiles either side of the fractional tile position f, and how far
+// it is towards the second, clamped at the edges of the image
+func neighbours(f float64, tiles int) (a, b int, weight float64) {
+	if f <= 0 {
+		return 0, 0, 0
+	}
+	if f >= float64(tiles-1) {
+		return tiles - 1, tiles - 1, 0
+	}
+	a = int(f)
+	return a, a + 1, f - float64(a)
+}
+
+func binOf(v float64) int {
+	return int(clamp(v)*(bins-1) + 0.5)
+}
@@ -0,0 +1,146 @@
+package tone
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"wombatlord/photerm/src/colour"
+)
+
+// Model measures the luminance of a colour from 0 for black to 1 for white,
+// and relights colours to a new luminance without changing their hue.
+type Model struct {
+	Luma    func(c color.RGBA) float64
+	Relight func(c color.RGBA, l float64) color.RGBA
+}
+
+// Rec601 weighs the gamma encoded channels, as color.GrayModel does.
+var Rec601 = Model{
+	Luma: luma601,
+	Relight: func(c color.RGBA, l float64) color.RGBA {
+		return scale(c, luma601(c), l, func(v float64) float64 { return v }, func(v float64) float64 { return v })
+	},
+}
+
+// Rec709 weighs the channels in linear light, which is how much light the colour gives off.
+var Rec709 = Model{
+	Luma: luma709,
+	Relight: func(c color.RGBA, l float64) color.RGBA {
+		return scale(c, luma709(c), l, colour.ToLinear, colour.ToSRGB)
+	},
+}
+
+// LabL is the CIELAB lightness L* scaled to 0 to 1, which is spaced evenly to the eye.
+var LabL = Model{
+	Luma: func(c color.RGBA) float64 {
+		return lightness(luma709(c))
+	},
+	Relight: func(c color.RGBA, l float64) color.RGBA {
+		return scale(c, luma709(c), luminance(l), colour.ToLinear, colour.ToSRGB)
+	},
+}
+
+func luma601(c color.RGBA) float64 {
+	return (0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)) / 255
+}
+
+func luma709(c color.RGBA) float64 {
+	r, g, b := colour.ToLinear(float64(c.R)/255), colour.ToLinear(float64(c.G)/255), colour.ToLinear(float64(c.B)/255)
+	return 0.2126*r + 0.7152*g + 0.0722*b
+}
+
+// Models is the mapping of name to luminance model.
+var Models = map[string]Model{
+	"601": Rec601,
+	"709": Rec709,
+	"lab": LabL,
+}
+
+// scale multiplies the channels of c, decoded by decode, so its luminance goes from l to target.
+// Black has no hue to keep, so it becomes the grey of the target luminance.
+func scale(c color.RGBA, l, target float64, decode, encode func(float64) float64) color.RGBA {
+	channel := func(v uint8) uint8 {
+		if l <= 0 {
+			return byteOf(encode(target))
+		}
+		return byteOf(encode(math.Min(1, decode(float64(v)/255)*target/l)))
+	}
+	return color.RGBA{R: channel(c.R), G: channel(c.G), B: channel(c.B), A: c.A}
+}
+
+// lightness converts relative luminance Y to L* / 100
+func lightness(y float64) float64 {
+	if y > 216.0/24389 {
+		return 1.16*math.Cbrt(y) - 0.16
+	}
+	return y * 24389 / 2700
+}
+
+// luminance converts L* / 100 back to relative luminance Y
+func luminance(l float64) float64 {
+	if l > 0.08 {
+		f := (l + 0.16) / 1.16
+		return f * f * f
+	}
+	return l * 2700 / 24389
+}
+
+func byteOf(v float64) uint8 {
+	return uint8(clamp(v)*255 + 0.5)
+}
+
+func clamp(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
+
+// Curve adjusts luminance. Gamma bends the midtones, above 1 lifting them, then contrast
+// stretches away from mid grey and brightness is added.
+type Curve struct {
+	Brightness, Contrast, Gamma float64
+}
+
+// Identity is the curve that changes nothing.
+var Identity = Curve{Brightness: 0, Contrast: 1, Gamma: 1}
+
+func (c Curve) Apply(l float64) float64 {
+	if c.Gamma > 0 && c.Gamma != 1 {
+		l = math.Pow(l, 1/c.Gamma)
+	}
+	return clamp((l-0.5)*c.Contrast + 0.5 + c.Brightness)
+}
+
+// Settings is a tone mapping, applied in the order of the fields: luminance is measured with the Model,
+// stretched to fill the range by AutoLevels, equalized with CLAHE when Clip is set and finally
+// shaped by the Curve.
+type Settings struct {
+	Model      Model
+	AutoLevels bool
+	// Clip is the CLAHE clip limit, 0 turns equalization off
+	Clip  float64
+	Curve Curve
+}
+
+// Map returns the luminance of every pixel of img row by row, before and after tone mapping.
+func (s Settings) Map(img image.Image) (before, after []float64) {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	before = make([]float64, 0, w*h)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			before = append(before, s.Model.Luma(color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)))
+		}
+	}
+
+	after = append([]float64{}, before...)
+	if s.AutoLevels {
+		Stretch(after)
+	}
+	if s.Clip > 0 {
+		Equalize(after, w, h, s.Clip)
+	}
+	for i, l := range after {
+		after[i] = s.Curve.Apply(l)
+	}
+	return before, after
+}
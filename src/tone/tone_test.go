@@ -0,0 +1,198 @@
+package tone
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-3
+}
+
+func TestCurve(t *testing.T) {
+	cases := []struct {
+		name     string
+		curve    Curve
+		in, want float64
+	}{
+		{"identity", Identity, 0.3, 0.3},
+		{"brighter", Curve{Brightness: 0.2, Contrast: 1, Gamma: 1}, 0.3, 0.5},
+		{"darker", Curve{Brightness: -0.2, Contrast: 1, Gamma: 1}, 0.3, 0.1},
+		{"brightness clips", Curve{Brightness: 0.5, Contrast: 1, Gamma: 1}, 0.7, 1},
+		{"contrast keeps mid grey", Curve{Contrast: 2, Gamma: 1}, 0.5, 0.5},
+		{"contrast", Curve{Contrast: 2, Gamma: 1}, 0.6, 0.7},
+		{"contrast clips", Curve{Contrast: 2, Gamma: 1}, 0.2, 0},
+		{"flat", Curve{Contrast: 0, Gamma: 1}, 0.9, 0.5},
+		{"gamma lifts", Curve{Contrast: 1, Gamma: 2}, 0.25, 0.5},
+		{"gamma sinks", Curve{Contrast: 1, Gamma: 0.5}, 0.5, 0.25},
+		{"gamma keeps white", Curve{Contrast: 1, Gamma: 2}, 1, 1},
+		// gamma is bent first, then contrast and brightness
+		{"in order", Curve{Brightness: 0.1, Contrast: 2, Gamma: 2}, 0.36, 0.8},
+	}
+	for _, c := range cases {
+		if got := c.curve.Apply(c.in); !near(got, c.want) {
+			t.Errorf("%s: %v came out as %v, want %v", c.name, c.in, got, c.want)
+		}
+	}
+}
+
+func TestModels(t *testing.T) {
+	green := color.RGBA{G: 255, A: 255}
+	// 119 is about 18% grey in linear light, L* 50
+	grey := color.RGBA{R: 119, G: 119, B: 119, A: 255}
+	cases := []struct {
+		name  string
+		model Model
+		in    color.RGBA
+		want  float64
+	}{
+		{"601 white", Rec601, color.RGBA{R: 255, G: 255, B: 255, A: 255}, 1},
+		{"601 green", Rec601, green, 0.587},
+		{"601 grey", Rec601, grey, 119.0 / 255},
+		{"709 black", Rec709, color.RGBA{A: 255}, 0},
+		{"709 green", Rec709, green, 0.7152},
+		{"709 grey", Rec709, grey, 0.1845},
+		{"lab grey", LabL, grey, 0.5003},
+		{"lab green", LabL, green, 0.8774},
+	}
+	for _, c := range cases {
+		if got := c.model.Luma(c.in); !near(got, c.want) {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+
+	for name, model := range Models {
+		orange := color.RGBA{R: 200, G: 100, B: 50, A: 9}
+		if got := model.Relight(orange, model.Luma(orange)); got != orange {
+			t.Errorf("%s: relit to its own luminance, %v came out as %v", name, orange, got)
+		}
+		// half the luminance keeps the hue, so the channels keep their order
+		if got := model.Relight(orange, model.Luma(orange)/2); !(got.R > got.G && got.G > got.B) || got.R >= orange.R || got.A != orange.A {
+			t.Errorf("%s: darkening %v gave %v", name, orange, got)
+		}
+		if got := model.Relight(color.RGBA{A: 255}, model.Luma(grey)); got != grey {
+			t.Errorf("%s: black relit gave %v, want %v", name, got, grey)
+		}
+	}
+}
+
+func TestStretch(t *testing.T) {
+	l := []float64{0.2, 0.3, 0.4, 0.5, 0.6}
+	Stretch(l)
+	for i, want := range []float64{0, 0.25, 0.5, 0.75, 1} {
+		if !near(l[i], want) {
+			t.Errorf("got %v, want %v", l, want)
+			break
+		}
+	}
+
+	flat := []float64{0.4, 0.4, 0.401}
+	Stretch(flat)
+	if flat[0] != 0.4 || flat[2] != 0.401 {
+		t.Errorf("a flat image was stretched to %v", flat)
+	}
+}
+
+// filled returns w by h samples of v
+func filled(w, h int, v float64) []float64 {
+	l := make([]float64, w*h)
+	for i := range l {
+		l[i] = v
+	}
+	return l
+}
+
+func TestTileMapping(t *testing.T) {
+	// half the tile black and half white
+	l := append(filled(4, 2, 0), filled(4, 2, 1)...)
+	cases := []struct {
+		name         string
+		clip         float64
+		black, white float64
+		mid          float64
+	}{
+		// without clipping, everything up to the black bin holds half the pixels
+		{"unclipped", bins, 0.5, 1, 0.5},
+		// clipped at the average bin, 1/256 of the pixels stay in each end bin and the rest
+		// are shared out between all of them, leaving the mapping all but a straight line
+		{"clipped at the average", 1, 1.0/bins + (1-2.0/bins)/bins, 1, 1.0/bins + (1-2.0/bins)*128/bins},
+		{"clipped at twice the average", 2, 2.0/bins + (1-4.0/bins)/bins, 1, 2.0/bins + (1-4.0/bins)*128/bins},
+	}
+	for _, c := range cases {
+		mapping := tileMapping(l, 4, 0, 0, 4, 4, c.clip)
+		if !near(mapping[0], c.black) || !near(mapping[bins-1], c.white) || !near(mapping[127], c.mid) {
+			t.Errorf("%s: black %v, mid %v, white %v, want %v, %v, %v",
+				c.name, mapping[0], mapping[127], mapping[bins-1], c.black, c.mid, c.white)
+		}
+	}
+
+	// the tile is only the part of l it covers
+	if mapping := tileMapping(l, 4, 0, 2, 4, 4, bins); !near(mapping[0], 0) || !near(mapping[bins-1], 1) {
+		t.Errorf("the white half mapped black to %v and white to %v", mapping[0], mapping[bins-1])
+	}
+}
+
+func TestEqualize(t *testing.T) {
+	// a flat image clipped at the average bin is left about where it was, not blown out.
+	// Grey is in bin 128, so the bins up to it keep 129 shares of what was clipped.
+	l := filled(40, 24, 0.5)
+	Equalize(l, 40, 24, 1)
+	for _, v := range l {
+		if !near(v, (1+129*(1-1.0/bins))/bins) {
+			t.Fatalf("clipped flat grey came out as %v", v)
+		}
+	}
+
+	// unclipped, every pixel of a flat image is at the top of its tile's histogram
+	l = filled(40, 24, 0.5)
+	Equalize(l, 40, 24, bins)
+	for _, v := range l {
+		if !near(v, 1) {
+			t.Fatalf("unclipped flat grey came out as %v", v)
+		}
+	}
+
+	// the left of the image is dark and the right light, but each side is equalized by its own
+	// tiles, so the same grey is lighter among dark pixels than among light ones
+	w, h := 64, 16
+	l = make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			l[y*w+x] = 0.1 + 0.8*float64(x/(w/2)) + 0.05*float64((x+y)%2)
+		}
+	}
+	l[8*w+2], l[8*w+w-3] = 0.5, 0.5
+	Equalize(l, w, h, 2)
+	if l[8*w+2] <= l[8*w+w-3] {
+		t.Errorf("grey among dark came out as %v, grey among light as %v", l[8*w+2], l[8*w+w-3])
+	}
+
+	Equalize(nil, 0, 0, 1)
+}
+
+func TestApplyTargets(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	img.SetRGBA(0, 0, color.RGBA{R: 51, G: 51, B: 51, A: 255})
+	img.SetRGBA(1, 0, color.RGBA{R: 102, G: 102, B: 102, A: 255})
+	brighter := Settings{Model: Rec601, Curve: Curve{Brightness: 0.2, Contrast: 1, Gamma: 1}}
+
+	cases := []struct {
+		target        Target
+		glyph, colour uint8
+	}{
+		{Glyphs, 102, 51},
+		{Colours, 51, 102},
+		{Both, 102, 102},
+	}
+	for _, c := range cases {
+		f := brighter.Apply(img, c.target)
+		if got := f.Glyph.GrayAt(0, 0).Y; got != c.glyph {
+			t.Errorf("%s: glyph brightness %d, want %d", c.target, got, c.glyph)
+		}
+		if got := color.RGBAModel.Convert(f.At(0, 0)).(color.RGBA).R; got != c.colour {
+			t.Errorf("%s: colour %d, want %d", c.target, got, c.colour)
+		}
+	}
+}
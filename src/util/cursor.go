@@ -83,3 +83,47 @@ func ClearScreenUp() string {
 func ClearEntireScreen() string {
 	return escape("[2J")
 }
+
+// SaveCursor returns ANSI escape sequence to save the cursor position.
+func SaveCursor() string {
+	return escape("7")
+}
+
+// RestoreCursor returns ANSI escape sequence to move the cursor
+// back to the position last saved.
+func RestoreCursor() string {
+	return escape("8")
+}
+
+// HideCursor returns ANSI escape sequence to hide the cursor.
+func HideCursor() string {
+	return escape("[?25l")
+}
+
+// ShowCursor returns ANSI escape sequence to show the cursor.
+func ShowCursor() string {
+	return escape("[?25h")
+}
+
+// EnterAltScreen returns ANSI escape sequence to switch to the
+// alternate screen, saving the cursor.
+func EnterAltScreen() string {
+	return escape("[?1049h")
+}
+
+// ExitAltScreen returns ANSI escape sequence to switch back to the
+// normal screen, restoring the cursor.
+func ExitAltScreen() string {
+	return escape("[?1049l")
+}
+
+// BeginSync returns ANSI escape sequence asking the terminal to hold
+// off drawing until EndSync, so a frame appears all at once.
+func BeginSync() string {
+	return escape("[?2026h")
+}
+
+// EndSync returns ANSI escape sequence to draw everything sent since BeginSync.
+func EndSync() string {
+	return escape("[?2026l")
+}
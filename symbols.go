@@ -0,0 +1,246 @@
+package main
+
+import (
+	"image"
+	"image/color"
+
+	"wombatlord/photerm/photerm_src"
+	"wombatlord/photerm/src/dither"
+	"wombatlord/photerm/src/util"
+)
+
+// The symbol renderer samples each cell as a grid of SymbolCols x SymbolRows pixels
+const (
+	SymbolCols = 4
+	SymbolRows = 8
+)
+
+// symbolMask has one bit per pixel of the cell, bit y*SymbolCols+x is set when
+// the pixel at x, y is covered by the foreground of the glyph.
+type symbolMask uint32
+
+// Symbol pairs a glyph with the shape of its foreground
+type Symbol struct {
+	Glyph rune
+	Mask  symbolMask
+}
+
+// maskOf builds a symbolMask by asking covered about the centre of every pixel.
+// The centre is normalised so u and v run from 0 to 1 across the cell.
+func maskOf(covered func(u, v float64) bool) (m symbolMask) {
+	for y := 0; y < SymbolRows; y++ {
+		for x := 0; x < SymbolCols; x++ {
+			u := (float64(x) + 0.5) / SymbolCols
+			v := (float64(y) + 0.5) / SymbolRows
+			if covered(u, v) {
+				m |= 1 << (y*SymbolCols + x)
+			}
+		}
+	}
+	return m
+}
+
+// rows and cols cover whole pixel rows and columns, which is all the block elements need
+func rows(from, to int) symbolMask {
+	return maskOf(func(_, v float64) bool { return v*SymbolRows >= float64(from) && v*SymbolRows < float64(to) })
+}
+
+func cols(from, to int) symbolMask {
+	return maskOf(func(u, _ float64) bool { return u*SymbolCols >= float64(from) && u*SymbolCols < float64(to) })
+}
+
+// sextantRows are the boundaries of the three sextant rows. Eight pixels don't split
+// evenly into three so the middle row is a pixel short.
+var sextantRows = [4]int{0, 3, 5, 8}
+
+// sextants returns the 60 sextant glyphs. The six cells are numbered left to right, top to
+// bottom and the codepoints skip the patterns already covered by the half blocks.
+func sextants() (syms []Symbol) {
+	const leftHalf, rightHalf = 0b010101, 0b101010
+	glyph := rune(0x1FB00)
+	for pattern := 1; pattern < 0b111111; pattern++ {
+		if pattern == leftHalf || pattern == rightHalf {
+			continue
+		}
+		var m symbolMask
+		for bit := 0; bit < 6; bit++ {
+			if pattern&(1<<bit) != 0 {
+				row, col := bit/2, bit%2
+				m |= rows(sextantRows[row], sextantRows[row+1]) & cols(col*2, col*2+2)
+			}
+		}
+		syms = append(syms, Symbol{Glyph: glyph, Mask: m})
+		glyph++
+	}
+	return syms
+}
+
+// wedge covers the side of the line from u0, v0 to u1, v1 that holds the corner cu, cv
+func wedge(u0, v0, u1, v1, cu, cv float64) symbolMask {
+	side := func(u, v float64) float64 { return (u1-u0)*(v-v0) - (v1-v0)*(u-u0) }
+	ref := side(cu, cv)
+	return maskOf(func(u, v float64) bool { return side(u, v)*ref > 0 })
+}
+
+// wedges returns the 22 smooth mosaic glyphs from U+1FB3C whose diagonals join points on the
+// sextant grid. The 22 after them are their complements, so they aren't needed.
+func wedges() []Symbol {
+	const top, upper, lower, btm = 0, 1.0 / 3, 2.0 / 3, 1
+	const left, centre, right = 0, 0.5, 1
+	lowerLeft := func(u0, v0, u1, v1 float64) symbolMask { return wedge(u0, v0, u1, v1, left, btm) }
+	lowerRight := func(u0, v0, u1, v1 float64) symbolMask { return wedge(u0, v0, u1, v1, right, btm) }
+	masks := []symbolMask{
+		lowerLeft(left, lower, centre, btm),
+		lowerLeft(left, lower, right, btm),
+		lowerLeft(left, upper, centre, btm),
+		lowerLeft(left, upper, right, btm),
+		lowerLeft(left, top, centre, btm),
+		lowerRight(left, upper, centre, top),
+		lowerRight(left, upper, right, top),
+		lowerRight(left, lower, centre, top),
+		lowerRight(left, lower, right, top),
+		lowerRight(left, btm, centre, top),
+		lowerRight(left, lower, right, upper),
+		lowerRight(centre, btm, right, lower),
+		lowerRight(left, btm, right, lower),
+		lowerRight(centre, btm, right, upper),
+		lowerRight(left, btm, right, upper),
+		lowerRight(centre, btm, right, top),
+		lowerLeft(centre, top, right, upper),
+		lowerLeft(left, top, right, upper),
+		lowerLeft(centre, top, right, lower),
+		lowerLeft(left, top, right, lower),
+		lowerLeft(centre, top, right, btm),
+		lowerLeft(left, upper, right, lower),
+	}
+	syms := make([]Symbol, len(masks))
+	for i, m := range masks {
+		syms[i] = Symbol{Glyph: rune(0x1FB3C + i), Mask: m}
+	}
+	return syms
+}
+
+// Symbols is the set of glyphs the symbol renderer chooses from. Only one of each
+// complementary pair is needed as swapping the colours gives the other.
+var Symbols = func() []Symbol {
+	top, btm, left, right := rows(0, 4), rows(4, 8), cols(0, 2), cols(2, 4)
+	syms := []Symbol{
+		// full and half blocks
+		{'█', rows(0, 8)},
+		{'▀', top},
+		{'▌', left},
+
+		// quadrants
+		{'▘', top & left},
+		{'▝', top & right},
+		{'▖', btm & left},
+		{'▗', btm & right},
+		{'▚', top&left | btm&right},
+
+		// eighths
+		{'▁', rows(7, 8)},
+		{'▂', rows(6, 8)},
+		{'▃', rows(5, 8)},
+		{'▅', rows(3, 8)},
+		{'▆', rows(2, 8)},
+		{'▇', rows(1, 8)},
+		{'▎', cols(0, 1)},
+		{'▊', cols(0, 3)},
+	}
+	return append(append(syms, sextants()...), wedges()...)
+}()
+
+// cellColours holds the colours of every pixel in a cell as floats for the error sums
+type cellColours [SymbolCols * SymbolRows][3]float64
+
+// bestSymbol returns the symbol and colour pair that minimise the squared error against the cell.
+// For a partition of the cell the best colours are the means of each side, so the error of a
+// symbol is the total sum of squares less what each side's mean accounts for.
+func bestSymbol(cell *cellColours) (best Symbol, fg, bg [3]float64) {
+	var total [3]float64
+	for _, c := range cell {
+		total[0], total[1], total[2] = total[0]+c[0], total[1]+c[1], total[2]+c[2]
+	}
+
+	bestFit := -1.0
+	for _, sym := range Symbols {
+		var on [3]float64
+		n := 0
+		for i, c := range cell {
+			if sym.Mask&(1<<i) != 0 {
+				on[0], on[1], on[2] = on[0]+c[0], on[1]+c[1], on[2]+c[2]
+				n++
+			}
+		}
+		off := [3]float64{total[0] - on[0], total[1] - on[1], total[2] - on[2]}
+
+		// maximising the explained sum of squares is the same as minimising the error
+		fit := 0.0
+		if n != 0 {
+			fit += (on[0]*on[0] + on[1]*on[1] + on[2]*on[2]) / float64(n)
+		}
+		if n != len(cell) {
+			fit += (off[0]*off[0] + off[1]*off[1] + off[2]*off[2]) / float64(len(cell)-n)
+		}
+		if fit <= bestFit {
+			continue
+		}
+
+		bestFit, best = fit, sym
+		fg = [3]float64{on[0] / float64(n), on[1] / float64(n), on[2] / float64(n)}
+		if n == len(cell) {
+			bg = fg
+		} else {
+			k := float64(len(cell) - n)
+			bg = [3]float64{off[0] / k, off[1] / k, off[2] / k}
+		}
+	}
+	return best, fg, bg
+}
+
+// inkOf rounds a float colour back to RGBA. A side of the cell can mix palette colours,
+// so when quantizing the mix goes back to the nearest of them.
+func inkOf(c [3]float64) color.RGBA {
+	rgb := color.RGBA{R: uint8(c[0] + 0.5), G: uint8(c[1] + 0.5), B: uint8(c[2] + 0.5)}
+	if palette := activePalette(); palette != nil {
+		return palette.Colour(palette.Nearest(rgb))
+	}
+	return rgb
+}
+
+// RenderSymbols returns the cells of a single frame by matching each SymbolCols x SymbolRows
+// block of pixels against the shapes of block, sextant and wedge glyphs, painting the best
+// match with the best foreground and background pair. The pixels are shaded and dithered by
+// the colour picker first, so with a small palette the glyphs pick out the dither pattern.
+func RenderSymbols(img image.Image, _ CharPalette, r photerm.Region) (grid Grid) {
+	grid = make(Grid, 0, (r.Btm-r.Top+SymbolRows-1)/SymbolRows)
+	colourAt := MakeColourPicker(img, r, dither.Methods[Args.Dither])
+	cell := cellColours{}
+
+	for y := r.Top; y < r.Btm; y += SymbolRows {
+		row := make([]Cell, 0, (r.Right-r.Left+SymbolCols-1)/SymbolCols)
+		for x := r.Left; x < r.Right; x += SymbolCols {
+			// collect the cell, repeating the edge pixels where the cell overhangs the region
+			shown := 0
+			for i := range cell {
+				px := util.Min(x+i%SymbolCols, r.Right-1)
+				py := util.Min(y+i/SymbolCols, r.Btm-1)
+				rgb := color.RGBAModel.Convert(img.At(px, py)).(color.RGBA)
+				ink := colourAt(px, py, rgb)
+				cell[i] = [3]float64{float64(ink.R), float64(ink.G), float64(ink.B)}
+				if !skipped(rgb) {
+					shown++
+				}
+			}
+			if shown == 0 {
+				row = append(row, Cell{Transparent: true})
+				continue
+			}
+
+			sym, fg, bg := bestSymbol(&cell)
+			row = append(row, Cell{Glyph: sym.Glyph, FG: inkOf(fg), HasFG: true, BG: inkOf(bg), HasBG: true})
+		}
+		grid = append(grid, row)
+	}
+	return grid
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"wombatlord/photerm/photerm_src"
+	"wombatlord/photerm/src/colour"
+)
+
+// paint fills a cell with fg where the mask is set and bg everywhere else
+func paint(m symbolMask, fg, bg [3]float64) (cell cellColours) {
+	for i := range cell {
+		cell[i] = bg
+		if m&(1<<i) != 0 {
+			cell[i] = fg
+		}
+	}
+	return cell
+}
+
+var (
+	inkRed  = [3]float64{255, 0, 0}
+	inkBlue = [3]float64{0, 0, 255}
+)
+
+func TestBestSymbolFitsEveryShape(t *testing.T) {
+	for _, sym := range Symbols {
+		cell := paint(sym.Mask, inkRed, inkBlue)
+		// a complement found earlier in the set fits as well with the colours swapped
+		best, fg, bg := bestSymbol(&cell)
+		if got := paint(best.Mask, fg, bg); got != cell {
+			t.Errorf("%c %U came out as %c", sym.Glyph, sym.Glyph, best.Glyph)
+		}
+	}
+}
+
+func TestBestSymbolGlyphs(t *testing.T) {
+	cases := []struct {
+		name  string
+		mask  symbolMask
+		glyph rune
+	}{
+		{"upper half", rows(0, 4), '▀'},
+		{"lower eighth", rows(7, 8), '▁'},
+		{"upper left quadrant", rows(0, 4) & cols(0, 2), '▘'},
+		{"upper left sextant", rows(0, 3) & cols(0, 2), 0x1FB00},
+		{"middle right sextant", rows(3, 5) & cols(2, 4), 0x1FB07},
+		{"lower left corner", wedge(0, 2.0/3, 0.5, 1, 0, 1), 0x1FB3C},
+		{"lower right diagonal", wedge(0, 1, 1, 1.0/3, 1, 1), 0x1FB4A},
+		{"shallow diagonal", wedge(0, 1.0/3, 1, 2.0/3, 0, 1), 0x1FB51},
+	}
+	for _, c := range cases {
+		cell := paint(c.mask, inkRed, inkBlue)
+		if best, fg, bg := bestSymbol(&cell); best.Glyph != c.glyph || fg != inkRed || bg != inkBlue {
+			t.Errorf("%s: got %c %U on %v over %v, want %c %U", c.name, best.Glyph, best.Glyph, fg, bg, c.glyph, c.glyph)
+		}
+	}
+}
+
+// every glyph is a block element or from the Symbols for Legacy Computing, never the
+// geometric shapes whose width is ambiguous in East Asian fonts
+func TestSymbolsAreBlocks(t *testing.T) {
+	for _, sym := range Symbols {
+		if !(sym.Glyph >= 0x2580 && sym.Glyph <= 0x259F) && !(sym.Glyph >= 0x1FB00 && sym.Glyph <= 0x1FB51) {
+			t.Errorf("%c %U isn't a block element", sym.Glyph, sym.Glyph)
+		}
+	}
+}
+
+func TestRenderSymbolsUsesThePalette(t *testing.T) {
+	defer func(args photerm.Cli) { Args = args }(Args)
+	Args.Colours, Args.Dither = photerm.Colours16, "floyd-steinberg"
+
+	// a flat grey between two palette greys comes out as a mix of the two
+	grey := color.RGBA{R: 160, G: 160, B: 160, A: 255}
+	img := image.NewRGBA(image.Rect(0, 0, SymbolCols*4, SymbolRows*2))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = grey.R, grey.G, grey.B, grey.A
+	}
+	grid := RenderSymbols(img, CharPalette{}, photerm.Region{Right: img.Rect.Dx(), Btm: img.Rect.Dy()})
+
+	mixed := false
+	for _, row := range grid {
+		for _, cell := range row {
+			for _, ink := range []color.RGBA{cell.FG, cell.BG} {
+				ink.A = 255
+				if colour.ANSI16.Colour(colour.ANSI16.Nearest(ink)) != ink {
+					t.Fatalf("%v isn't in the palette", ink)
+				}
+			}
+			mixed = mixed || cell.FG != cell.BG
+		}
+	}
+	if !mixed {
+		t.Error("the grey wasn't dithered")
+	}
+}
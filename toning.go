@@ -0,0 +1,33 @@
+package main
+
+import (
+	"image"
+
+	"wombatlord/photerm/photerm_src"
+	"wombatlord/photerm/src/tone"
+)
+
+// ToneSettings returns the tone mapping picked by Args, and false when it would leave frames as they are.
+func ToneSettings() (settings tone.Settings, ok bool) {
+	settings = tone.Settings{
+		Model:      tone.Models[Args.Luma],
+		AutoLevels: Args.Levels,
+		Clip:       Args.Equalize,
+		Curve:      tone.Curve{Brightness: Args.Brighten, Contrast: Args.Contrast, Gamma: Args.Gamma},
+	}
+	// the default model is the one brightnessOf uses anyway
+	ok = Args.Luma != "601" || settings.AutoLevels || settings.Clip > 0 || settings.Curve != tone.Identity
+	return settings, ok
+}
+
+// AppendToningStep attaches the tone mapping picked by Args to the image buffer, if there's any to do.
+func AppendToningStep(in <-chan image.Image) <-chan image.Image {
+	settings, ok := ToneSettings()
+	if !ok {
+		return in
+	}
+	target := tone.Target(Args.Tone)
+	return photerm.AppendMappingStep(in, func(f *photerm.Frame) image.Image {
+		return settings.Apply(f.Image, target)
+	})
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"image"
+	"image/color"
+
+	"wombatlord/photerm/photerm_src"
+)
+
+// checkerSize is the width of a checkerboard square, in pixels of the scaled image
+const checkerSize = 4
+
+// checkerLight and checkerDark are the squares of the checkerboard, the greys image editors use
+var (
+	checkerLight = color.RGBA{R: 204, G: 204, B: 204, A: 255}
+	checkerDark  = color.RGBA{R: 153, G: 153, B: 153, A: 255}
+)
+
+// Backdrop is the colour transparency is composited over, parsed from the Backdrop Arg
+var Backdrop = color.RGBA{A: 255}
+
+// skipCut is the alpha cut used when skipping transparent cells and no cut is given, so the
+// faint edges of a sprite don't cover what's underneath.
+const skipCut = 128
+
+// alphaCut returns the alpha below which a pixel counts as fully transparent
+func alphaCut() uint8 {
+	if Args.AlphaCut == photerm.NotSet && Args.Alpha == photerm.Skip {
+		return skipCut
+	}
+	return uint8(Args.AlphaCut)
+}
+
+// backdropAt returns what shows through the pixel at x, y from the top left of a frame
+func backdropAt(x, y int) color.RGBA {
+	if Args.Alpha != photerm.Checker {
+		return Backdrop
+	}
+	if (x/checkerSize+y/checkerSize)%2 == 0 {
+		return checkerLight
+	}
+	return checkerDark
+}
+
+// composite returns c over the backdrop. Colours from the image package are premultiplied,
+// so the backdrop only has to be added in proportion to the transparency.
+func composite(c, backdrop color.RGBA) color.RGBA {
+	k := 255 - int(c.A)
+	return color.RGBA{
+		R: c.R + uint8(int(backdrop.R)*k/255),
+		G: c.G + uint8(int(backdrop.G)*k/255),
+		B: c.B + uint8(int(backdrop.B)*k/255),
+		A: 255,
+	}
+}
+
+// AppendCompositingStep attaches compositing frames over their backdrop to the image buffer, which
+// leaves every pixel opaque. When skipping, the pixels under the alpha cut are left with an alpha
+// of 0 for the renderers to skip, carrying the backdrop colour for any that can't skip them.
+func AppendCompositingStep(in <-chan image.Image) <-chan image.Image {
+	cut := alphaCut()
+	return photerm.AppendMappingStep(in, func(f *photerm.Frame) image.Image {
+		if img, ok := f.Image.(interface{ Opaque() bool }); ok && img.Opaque() {
+			return f.Image
+		}
+		return shadeImage(f.Image, func(x, y int, c color.RGBA) color.RGBA {
+			backdrop := backdropAt(x, y)
+			if c.A >= cut {
+				return composite(c, backdrop)
+			}
+			if Args.Alpha == photerm.Skip {
+				backdrop.A = 0
+			}
+			return backdrop
+		})
+	})
+}
+
+// skipped reports whether a pixel whose colour is rgb should be left undrawn
+func skipped(rgb color.RGBA) bool {
+	return Args.Alpha == photerm.Skip && rgb.A == 0
+}
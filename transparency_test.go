@@ -0,0 +1,94 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"wombatlord/photerm/photerm_src"
+)
+
+func TestComposite(t *testing.T) {
+	white, black := color.RGBA{R: 255, G: 255, B: 255, A: 255}, color.RGBA{A: 255}
+	cases := []struct {
+		name        string
+		c, backdrop color.RGBA
+		want        color.RGBA
+	}{
+		{"opaque", color.RGBA{R: 10, G: 20, B: 30, A: 255}, white, color.RGBA{R: 10, G: 20, B: 30, A: 255}},
+		{"clear", color.RGBA{}, white, white},
+		// premultiplied, so half red is 128 of red at 128 alpha
+		{"half over white", color.RGBA{R: 128, A: 128}, white, color.RGBA{R: 255, G: 127, B: 127, A: 255}},
+		{"half over black", color.RGBA{R: 128, A: 128}, black, color.RGBA{R: 128, A: 255}},
+		{"faint over grey", color.RGBA{G: 51, A: 51}, color.RGBA{R: 100, G: 100, B: 100, A: 255}, color.RGBA{R: 80, G: 131, B: 80, A: 255}},
+	}
+	for _, c := range cases {
+		if got := composite(c.c, c.backdrop); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+// composited sends img through the compositing step
+func composited(img image.Image) *image.RGBA {
+	in := make(chan image.Image, 1)
+	in <- img
+	close(in)
+	out := <-AppendCompositingStep(in)
+	return photerm.FrameOf(out).Image.(*image.RGBA)
+}
+
+func TestCompositingStep(t *testing.T) {
+	defer func(args photerm.Cli, backdrop color.RGBA) { Args, Backdrop = args, backdrop }(Args, Backdrop)
+	Backdrop = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+
+	// red fading out to the right, non-premultiplied as decoders give it
+	img := image.NewNRGBA(image.Rect(0, 0, 6, 1))
+	for x, a := range []uint8{255, 200, 100, 0, 0, 100} {
+		img.SetNRGBA(x, 0, color.NRGBA{R: 255, A: a})
+	}
+	// red of alpha a over a grey backdrop, with what shows through rounded down
+	overGrey := func(a, grey uint8) color.RGBA {
+		through := uint8(int(grey) * (255 - int(a)) / 255)
+		return color.RGBA{R: a + through, G: through, B: through, A: 255}
+	}
+	over := func(a uint8) color.RGBA { return overGrey(a, 255) }
+	skip := func(c color.RGBA) color.RGBA { c.A = 0; return c }
+
+	cases := []struct {
+		name string
+		mode photerm.AlphaMode
+		cut  int
+		want []color.RGBA
+	}{
+		{"over", photerm.Over, 0, []color.RGBA{over(255), over(200), over(100), Backdrop, Backdrop, over(100)}},
+		{"over with a cut", photerm.Over, 150, []color.RGBA{over(255), over(200), Backdrop, Backdrop, Backdrop, Backdrop}},
+		// the squares are checkerSize wide, so the last two pixels are over the dark one
+		{"checker", photerm.Checker, 0, []color.RGBA{over(255), overGrey(200, checkerLight.R), overGrey(100, checkerLight.R),
+			checkerLight, checkerDark, overGrey(100, checkerDark.R)}},
+		// pixels under the cut are left for the renderers to skip, with the backdrop for those that can't
+		{"skip", photerm.Skip, 0, []color.RGBA{over(255), over(200), skip(Backdrop), skip(Backdrop), skip(Backdrop), skip(Backdrop)}},
+		{"skip with a cut", photerm.Skip, 50, []color.RGBA{over(255), over(200), over(100), skip(Backdrop), skip(Backdrop), over(100)}},
+	}
+	for _, c := range cases {
+		Args.Alpha, Args.AlphaCut = c.mode, c.cut
+		out := composited(img)
+		for x, want := range c.want {
+			if got := out.RGBAAt(x, 0); got != want {
+				t.Errorf("%s: pixel %d came out as %v, want %v", c.name, x, got, want)
+			}
+			if got := skipped(out.RGBAAt(x, 0)); got != (want.A == 0) {
+				t.Errorf("%s: pixel %d skipped is %v", c.name, x, got)
+			}
+		}
+	}
+
+	// opaque frames are passed on as they are
+	opaque := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	for i := 3; i < len(opaque.Pix); i += 4 {
+		opaque.Pix[i] = 255
+	}
+	if out := composited(opaque); out != opaque {
+		t.Error("an opaque frame was copied")
+	}
+}